@@ -0,0 +1,25 @@
+package zip
+
+import (
+	"bytes"
+	"github.com/andybalholm/brotli"
+	"io/ioutil"
+)
+
+// brotli压缩数据
+func Brotli(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := brotli.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// 解压brotli数据
+func Unbrotli(data []byte) ([]byte, error) {
+	return ioutil.ReadAll(brotli.NewReader(bytes.NewReader(data)))
+}
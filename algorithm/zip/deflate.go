@@ -0,0 +1,30 @@
+package zip
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+)
+
+// deflate压缩数据，按HTTP Content-Encoding: deflate的约定使用zlib格式
+func Deflate(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// 解压zlib格式的deflate数据
+func Inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,70 @@
+/*
+加密配置值，输出可直接写入配置文件的ENC(...)值
+
+	confcrypt -keygen                 生成主密钥
+	confcrypt [-key hex] value...     加密参数中的值，无参数时逐行加密标准输入
+	confcrypt -d [-key hex] ENC(...)  解密，用于核对
+
+主密钥默认从环境变量FRAMEWORK_MASTER_KEY或FRAMEWORK_MASTER_KEY_FILE读取，
+运行应用时须提供相同的主密钥
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"xianhetian.com/framework/config/secret"
+)
+
+func main() {
+	keygen := flag.Bool("keygen", false, "generate a new master key")
+	decrypt := flag.Bool("d", false, "decrypt ENC(...) values instead of encrypting")
+	hexKey := flag.String("key", "", "master key in hex (default from "+secret.EnvKey+" or "+secret.EnvKeyFile+")")
+	flag.Parse()
+
+	if *keygen {
+		k, err := secret.GenerateKey()
+		exitOn(err)
+		fmt.Println(k)
+		return
+	}
+	var key []byte
+	var err error
+	if *hexKey != "" {
+		key, err = secret.ParseKey(*hexKey)
+	} else {
+		key, err = secret.KeyFromEnv()
+	}
+	exitOn(err)
+
+	convert := func(v string) {
+		var out string
+		if *decrypt {
+			out, err = secret.Decrypt(key, v)
+		} else {
+			out, err = secret.Encrypt(key, v)
+		}
+		exitOn(err)
+		fmt.Println(out)
+	}
+	if flag.NArg() > 0 {
+		for _, v := range flag.Args() {
+			convert(v)
+		}
+		return
+	}
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		convert(s.Text())
+	}
+	exitOn(s.Err())
+}
+
+func exitOn(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "confcrypt:", err)
+		os.Exit(1)
+	}
+}
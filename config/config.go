@@ -1,12 +1,15 @@
 package config
 
 import (
-	"bufio"
-	"io"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"xianhetian.com/framework/config/secret"
 )
 
 const (
@@ -15,61 +18,219 @@ const (
 	VendorPath = "/vendor/xianhetian.com/framework"
 )
 
-var Config properties
+/*
+默认配置，其它包的配置项均从这里读取；首次使用时按DefaultLoadOptions加载，导入本包不读取任何配置；
+配置文件无法读取或解析、ENC(...)值无法解密（如未提供主密钥）时panic，不会以不完整的配置运行；
+logger、http等包在初始化时即读取配置，因此加载通常发生在main之前；
+配置项config_watch=true时开启重新加载，检查间隔为config_watch_interval，默认5s
+*/
+var Config = &Store{lazy: true}
 
-type part struct {
-	vals map[string]string
+// 并发安全的配置存储，读取使用不可变快照，写入时整体替换
+type Store struct {
+	snap   atomic.Value                // *snapshot，合并后的配置，变化时整体替换
+	mu     sync.Mutex                  // 保护layers与files的写入
+	layers [layerCount]map[string]item // 各来源的配置，按优先级从低到高
+	files  []string                    // 已加载的配置文件，按加载顺序
+	key    []byte                      // 解密ENC(...)值的主密钥
+	stop   func()                      // 停止Watch的检查
+	subMu  sync.Mutex
+	subs   map[int]*subscriber
+	nextID int
+
+	lazy    bool      // 是否在首次使用时按DefaultLoadOptions加载，仅用于Config
+	once    sync.Once // 保证默认配置只加载一次
+	loadErr error     // 默认配置的加载错误
+}
+
+// 加载配置的选项
+type LoadOptions struct {
+	Defaults  map[string]string // 默认值
+	Files     []string          // 配置文件，按顺序加载，后加载的优先
+	EnvPrefix string            // 环境变量前缀，为空时不读取环境变量
+	Args      []string          // 命令行参数，读取其中的--set key=value
+	Watch     time.Duration     // 大于0时按该间隔检查配置文件并重新加载
+	// 解密ENC(...)值的主密钥（16进制），为空时从环境变量FRAMEWORK_MASTER_KEY或FRAMEWORK_MASTER_KEY_FILE读取
+	MasterKey string
+}
+
+// 返回一个空的配置存储，用于测试或独立的配置
+func New() *Store {
+	return &Store{}
 }
 
-type properties struct {
-	values map[string]string
+// 按选项创建配置存储，配置文件读取失败或加密值无法解密时返回错误
+func Load(o LoadOptions) (*Store, error) {
+	p := New()
+	if err := p.apply(o); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
 }
 
-func init() {
-	var err error
-	var appConfigPath string
-	appPath, err := os.Getwd()
+/*
+依次加载各来源，ENC(...)值解密失败时该项不加载，其余配置项与之后的来源照常加载，
+最后返回第一个错误；主密钥无效或配置文件无法读取时立即返回
+*/
+func (p *Store) apply(o LoadOptions) error {
+	if o.MasterKey != "" {
+		if err := p.SetMasterKey(o.MasterKey); err != nil {
+			return err
+		}
+	} else if k, err := secret.KeyFromEnv(); err == nil {
+		p.key = k
+	} else if err != secret.ErrNoKey {
+		return err
+	}
+	if len(o.Defaults) > 0 {
+		items := make(map[string]item, len(o.Defaults))
+		for k, v := range o.Defaults {
+			items[k] = item{value: v, source: SourceDefault}
+		}
+		p.load(layerDefault, items)
+	}
+	var first error
+	keep := func(err error) error {
+		if _, ok := err.(*KeyError); ok {
+			if first == nil {
+				first = err
+			}
+			return nil
+		}
+		return err
+	}
+	for _, path := range o.Files {
+		if err := keep(p.LoadFile(path)); err != nil {
+			return err
+		}
+	}
+	if o.EnvPrefix != "" {
+		if err := keep(p.LoadEnv(o.EnvPrefix)); err != nil {
+			return err
+		}
+	}
+	if len(o.Args) > 0 {
+		if err := keep(p.LoadArgs(o.Args)); err != nil {
+			return err
+		}
+	}
+	if o.Watch > 0 {
+		p.Watch(o.Watch)
+	}
+	return first
+}
+
+/*
+返回默认配置的加载选项：配置文件、FRAMEWORK_开头的环境变量与命令行参数
+配置文件路径可由命令行参数--framework-config或环境变量FRAMEWORK_CONFIG指定，
+未指定时在工作目录及vendor目录的config目录下查找，见findFile
+*/
+func DefaultLoadOptions() (LoadOptions, error) {
+	o := LoadOptions{EnvPrefix: EnvPrefix, Args: os.Args[1:]}
+	path := argConfig(o.Args)
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		appPath, err := os.Getwd()
+		if err != nil {
+			return o, err
+		}
+		for _, dir := range []string{appPath, appPath + VendorPath} {
+			if path = findFile(filepath.Join(dir, DirName)); path != "" {
+				break
+			}
+		}
+	}
+	if path != "" {
+		o.Files = []string{path}
+	}
+	return o, nil
+}
+
+// 立即加载默认配置，加载失败时返回错误而不panic；默认配置已被使用过时返回当时的加载结果
+func LoadDefault() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Config.loadErr
+		}
+	}()
+	Config.ensure()
+	return Config.loadErr
+}
+
+// 默认配置首次使用时加载，读取其它配置前调用，调用时不能持有p.mu
+func (p *Store) ensure() {
+	if p.lazy {
+		p.once.Do(p.loadDefault)
+	}
+}
+
+/*
+加载到临时的Store再整体替换，避免加载过程中重入ensure；
+失败时保留已加载的配置项后panic，recover后仍可读取这些配置项
+*/
+func (p *Store) loadDefault() {
+	q := New()
+	o, err := DefaultLoadOptions()
+	if err == nil {
+		err = q.apply(o)
+	}
+	p.mu.Lock()
+	p.layers = q.layers
+	p.files = q.files
+	p.key = q.key
+	p.snap.Store(q.loaded())
+	p.mu.Unlock()
 	if err != nil {
-		panic(err)
+		p.loadErr = fmt.Errorf("config: load default config: %v", err)
+		panic(p.loadErr)
+	}
+	if q.DefaultBool("config_watch", "false") {
+		p.watch(q.DefaultDuration("config_watch_interval", "5s"))
 	}
-	appConfigPath = filepath.Join(appPath, DirName, FileName)
-	if !fileExists(appConfigPath) {
-		appPath += VendorPath
-		appConfigPath = filepath.Join(appPath, DirName, FileName)
-		if !fileExists(appConfigPath) {
-			return
+}
+
+// 在目录中查找配置文件，优先framework.conf，其次framework.yaml、framework.yml、framework.toml、framework.json
+func findFile(dir string) string {
+	name := strings.TrimSuffix(FileName, filepath.Ext(FileName))
+	for _, file := range []string{FileName, name + ".yaml", name + ".yml", name + ".toml", name + ".json"} {
+		if path := filepath.Join(dir, file); fileExists(path) {
+			return path
 		}
 	}
-	Init(appConfigPath)
+	return ""
 }
 
-func (p *properties) Set(k, v string) {
-	Config.values[k] = v
+// 在运行时设置配置项，优先级最高；v按明文保存，不解密ENC(...)
+func (p *Store) Set(k, v string) {
+	p.load(layerRuntime, map[string]item{k: {value: v, source: SourceRuntime}})
 }
 
-func (p *properties) Get(key string) string {
-	v, _ := p.values[key]
+func (p *Store) Get(key string) string {
+	v, _ := p.current().values[key]
 	return v
 }
 
-func (p *properties) String(key string) string {
+func (p *Store) String(key string) string {
 	return p.Get(key)
 }
 
-func (p *properties) DefaultString(key string, defaultVal string) string {
+func (p *Store) DefaultString(key string, defaultVal string) string {
 	if v := p.Get(key); v != "" {
 		return v
 	}
 	return defaultVal
 }
 
-func (p *properties) Int(key string) int {
+func (p *Store) Int(key string) int {
 	v := p.Get(key)
 	v2, _ := strconv.Atoi(v)
 	return v2
 }
 
-func (p *properties) DefaultInt(key string, defaultVal string) int {
+func (p *Store) DefaultInt(key string, defaultVal string) int {
 	result := defaultVal
 	if v := p.Get(key); v != "" {
 		result = v
@@ -78,13 +239,13 @@ func (p *properties) DefaultInt(key string, defaultVal string) int {
 	return v2
 }
 
-func (p *properties) Float(key string) float64 {
+func (p *Store) Float(key string) float64 {
 	v := p.Get(key)
 	v2, _ := strconv.ParseFloat(v, 10)
 	return v2
 }
 
-func (p *properties) DefaultFloat(key string, defaultVal string) float64 {
+func (p *Store) DefaultFloat(key string, defaultVal string) float64 {
 	result := defaultVal
 	if v := p.Get(key); v != "" {
 		result = v
@@ -93,7 +254,7 @@ func (p *properties) DefaultFloat(key string, defaultVal string) float64 {
 	return v2
 }
 
-func (p *properties) Bool(key string) bool {
+func (p *Store) Bool(key string) bool {
 	v := p.Get(key)
 	if v == "true" {
 		return true
@@ -101,7 +262,7 @@ func (p *properties) Bool(key string) bool {
 	return false
 }
 
-func (p *properties) DefaultBool(key string, defaultVal string) bool {
+func (p *Store) DefaultBool(key string, defaultVal string) bool {
 	result := defaultVal
 	if v := p.Get(key); v != "" {
 		result = v
@@ -112,59 +273,10 @@ func (p *properties) DefaultBool(key string, defaultVal string) bool {
 	return false
 }
 
-func (part *part) initPath(path string) {
-	part.vals = make(map[string]string)
-	f, err := os.Open(path)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-	r := bufio.NewReader(f)
-	for {
-		b, _, err := r.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			panic(err)
-		}
-		s := strings.TrimSpace(string(b))
-		if strings.Index(s, "#") == 0 {
-			continue
-		}
-		index := strings.Index(s, "=")
-		if index < 0 {
-			continue
-		}
-		first := strings.TrimSpace(s[:index])
-		if len(first) == 0 {
-			continue
-		}
-		second := strings.TrimSpace(s[index+1:])
-		pos := strings.Index(second, "\t#")
-		if pos > -1 {
-			second = second[0:pos]
-		}
-		pos = strings.Index(second, " #")
-		if pos > -1 {
-			second = second[0:pos]
-		}
-		if len(second) == 0 {
-			continue
-		}
-		key := first
-		part.vals[key] = strings.TrimSpace(second)
-	}
-}
-
+// 读取配置文件并合并到Config，失败时panic；新代码应使用Config.LoadFile
 func Init(path string) {
-	data := new(part)
-	data.initPath(path)
-	if Config.values == nil {
-		Config.values = make(map[string]string)
-	}
-	for k, v := range data.vals {
-		Config.Set(k, v)
+	if err := Config.LoadFile(path); err != nil {
+		panic(err)
 	}
 }
 
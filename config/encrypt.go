@@ -0,0 +1,46 @@
+package config
+
+import "xianhetian.com/framework/config/secret"
+
+// 设置解密ENC(...)值使用的主密钥（16进制），只影响之后加载的配置
+func (p *Store) SetMasterKey(hexKey string) error {
+	k, err := secret.ParseKey(hexKey)
+	if err != nil {
+		return err
+	}
+	p.ensure()
+	p.mu.Lock()
+	p.key = k
+	p.mu.Unlock()
+	return nil
+}
+
+/*
+就地解密ENC(...)格式的值，解密失败的项从items中删除，其余项照常保留；
+有失败时返回键名最小的一项的*KeyError
+*/
+func (p *Store) decrypt(items map[string]item) error {
+	p.ensure()
+	p.mu.Lock()
+	key := p.key
+	p.mu.Unlock()
+	var first *KeyError
+	for k, it := range items {
+		if !secret.IsEncrypted(it.value) {
+			continue
+		}
+		v, err := secret.Decrypt(key, it.value)
+		if err != nil {
+			delete(items, k)
+			if first == nil || k < first.Key {
+				first = &KeyError{Key: k, Value: it.value, Err: err}
+			}
+			continue
+		}
+		items[k] = item{value: v, source: it.source, secret: true}
+	}
+	if first != nil {
+		return first
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+配置文件格式，将文件内容解析为键值
+嵌套的键以"."连接（如http: {timeout: 10}为http.timeout），标量数组以逗号连接为列表，
+对象数组的键带下标（如servers.0.host）
+*/
+type Format func(r io.Reader) (map[string]string, error)
+
+var (
+	formatMu sync.RWMutex
+	formats  = map[string]Format{
+		".conf":       Properties,
+		".properties": Properties,
+		".ini":        Properties,
+		".json":       JSON,
+		".yaml":       YAML,
+		".yml":        YAML,
+		".toml":       TOML,
+	}
+)
+
+// 注册扩展名（如".hcl"）对应的文件格式，已存在时覆盖
+func RegisterFormat(ext string, f Format) {
+	formatMu.Lock()
+	defer formatMu.Unlock()
+	formats[strings.ToLower(ext)] = f
+}
+
+// 按扩展名选择格式读取配置文件，未注册的扩展名按Properties格式读取
+func ReadFile(path string) (map[string]string, error) {
+	formatMu.RLock()
+	f, ok := formats[strings.ToLower(filepath.Ext(path))]
+	formatMu.RUnlock()
+	if !ok {
+		f = Properties
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	vals, err := f(file)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %v", path, err)
+	}
+	return vals, nil
+}
+
+// key = value格式，#开头为注释，[section]之后的键保存为section.key
+func Properties(r io.Reader) (map[string]string, error) {
+	vals := make(map[string]string)
+	br := bufio.NewReader(r)
+	section := ""
+	for {
+		b, _, err := br.ReadLine()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		s := strings.TrimSpace(string(b))
+		if strings.Index(s, "#") == 0 {
+			continue
+		}
+		if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+			section = strings.TrimSpace(s[1 : len(s)-1])
+			continue
+		}
+		index := strings.Index(s, "=")
+		if index < 0 {
+			continue
+		}
+		first := strings.TrimSpace(s[:index])
+		if len(first) == 0 {
+			continue
+		}
+		second := strings.TrimSpace(s[index+1:])
+		pos := strings.Index(second, "\t#")
+		if pos > -1 {
+			second = second[0:pos]
+		}
+		pos = strings.Index(second, " #")
+		if pos > -1 {
+			second = second[0:pos]
+		}
+		if len(second) == 0 {
+			continue
+		}
+		key := first
+		if section != "" {
+			key = section + "." + first
+		}
+		vals[key] = strings.TrimSpace(second)
+	}
+	return vals, nil
+}
+
+// JSON格式，顶层须为对象
+func JSON(r io.Reader) (map[string]string, error) {
+	var m map[string]interface{}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return flatten(m), nil
+}
+
+// YAML格式，顶层须为映射
+func YAML(r io.Reader) (map[string]string, error) {
+	var m map[string]interface{}
+	if err := yaml.NewDecoder(r).Decode(&m); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return flatten(m), nil
+}
+
+// TOML格式，表对应段
+func TOML(r io.Reader) (map[string]string, error) {
+	var m map[string]interface{}
+	if _, err := toml.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return flatten(m), nil
+}
+
+func flatten(m map[string]interface{}) map[string]string {
+	vals := make(map[string]string)
+	flattenTo(vals, "", m)
+	return vals
+}
+
+func flattenTo(vals map[string]string, key string, v interface{}) {
+	join := func(k string) string {
+		if key == "" {
+			return k
+		}
+		return key + "." + k
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range t {
+			flattenTo(vals, join(k), sub)
+		}
+	case map[interface{}]interface{}:
+		for k, sub := range t {
+			flattenTo(vals, join(fmt.Sprint(k)), sub)
+		}
+	case []map[string]interface{}:
+		for i, sub := range t {
+			flattenTo(vals, join(strconv.Itoa(i)), sub)
+		}
+	case []interface{}:
+		var list []string
+		for i, sub := range t {
+			switch sub.(type) {
+			case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+				flattenTo(vals, join(strconv.Itoa(i)), sub)
+			default:
+				list = append(list, scalar(sub))
+			}
+		}
+		if len(list) > 0 || len(t) == 0 {
+			vals[key] = strings.Join(list, ",")
+		}
+	default:
+		vals[key] = scalar(v)
+	}
+}
+
+func scalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return t.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// 返回已注册的扩展名，按字典序排列
+func Formats() []string {
+	formatMu.RLock()
+	defer formatMu.RUnlock()
+	exts := make([]string, 0, len(formats))
+	for ext := range formats {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 合并后的配置，创建后不再修改
+type snapshot struct {
+	values  map[string]string
+	sources map[string]string
+	secrets map[string]bool
+}
+
+var emptySnapshot = &snapshot{values: map[string]string{}, sources: map[string]string{}, secrets: map[string]bool{}}
+
+// 配置项的变化，Old为空表示新增，New为空表示删除
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+type subscriber struct {
+	keys []string // 为空时订阅所有配置项，以"."或"*"结尾时按前缀匹配
+	fn   func(changes []Change)
+}
+
+func (p *Store) current() *snapshot {
+	p.ensure()
+	return p.loaded()
+}
+
+// 返回当前快照，不触发默认配置的加载
+func (p *Store) loaded() *snapshot {
+	if s, ok := p.snap.Load().(*snapshot); ok {
+		return s
+	}
+	return emptySnapshot
+}
+
+/*
+订阅配置项的变化，keys为空时订阅所有配置项，以"."或"*"结尾时按前缀匹配（如"http."）；
+配置文件重新加载、Set等使配置项变化时，在引起变化的goroutine中同步调用fn，
+changes只包含订阅的配置项；返回取消订阅的函数
+*/
+func (p *Store) Subscribe(fn func(changes []Change), keys ...string) func() {
+	p.ensure()
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	if p.subs == nil {
+		p.subs = make(map[int]*subscriber)
+	}
+	id := p.nextID
+	p.nextID++
+	p.subs[id] = &subscriber{keys: keys, fn: fn}
+	return func() {
+		p.subMu.Lock()
+		delete(p.subs, id)
+		p.subMu.Unlock()
+	}
+}
+
+// 订阅单个配置项的变化
+func (p *Store) OnChange(key string, fn func(old, new string)) func() {
+	return p.Subscribe(func(changes []Change) {
+		for _, c := range changes {
+			fn(c.Old, c.New)
+		}
+	}, key)
+}
+
+/*
+重新读取已加载的配置文件并整体替换文件层，文件中删除的配置项随之删除；
+读取失败时保留原有配置并返回错误
+*/
+func (p *Store) Reload() error {
+	p.ensure()
+	p.mu.Lock()
+	files := append([]string(nil), p.files...)
+	p.mu.Unlock()
+	layer := make(map[string]item)
+	for _, path := range files {
+		vals, err := ReadFile(path)
+		if err != nil {
+			return err
+		}
+		items := fileItems(path, vals)
+		if err = p.decrypt(items); err != nil {
+			return err
+		}
+		for k, it := range items {
+			layer[k] = it
+		}
+	}
+	p.mu.Lock()
+	p.layers[layerFile] = layer
+	changes := p.merge()
+	p.mu.Unlock()
+	p.notify(changes)
+	return nil
+}
+
+/*
+每隔interval检查已加载的配置文件，修改时间或大小变化时调用Reload，返回停止检查的函数；
+重复调用时停止之前的检查，Close也会停止检查
+可通过配置项config_watch=true在加载默认配置时开启，检查间隔为config_watch_interval，默认5s
+*/
+func (p *Store) Watch(interval time.Duration) func() {
+	p.ensure()
+	return p.watch(interval)
+}
+
+func (p *Store) watch(interval time.Duration) func() {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := p.stat()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			cur := p.stat()
+			if cur == last {
+				continue
+			}
+			if err := p.Reload(); err != nil {
+				// 文件可能正在写入，下次检查时重试
+				log.Printf("config: reload failed: %v", err)
+				continue
+			}
+			last = cur
+		}
+	}()
+	var once sync.Once
+	fn := func() {
+		once.Do(func() { close(stop) })
+	}
+	p.mu.Lock()
+	prev := p.stop
+	p.stop = fn
+	p.mu.Unlock()
+	if prev != nil {
+		prev()
+	}
+	return fn
+}
+
+// 停止Watch开启的检查
+func (p *Store) Close() {
+	p.mu.Lock()
+	stop := p.stop
+	p.stop = nil
+	p.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// 返回已加载文件的修改时间与大小摘要
+func (p *Store) stat() string {
+	p.mu.Lock()
+	files := append([]string(nil), p.files...)
+	p.mu.Unlock()
+	var b strings.Builder
+	for _, path := range files {
+		if fi, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, fi.ModTime().UnixNano(), fi.Size())
+		}
+	}
+	return b.String()
+}
+
+func (p *Store) notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	p.subMu.Lock()
+	subs := make([]*subscriber, 0, len(p.subs))
+	for _, s := range p.subs {
+		subs = append(subs, s)
+	}
+	p.subMu.Unlock()
+	for _, s := range subs {
+		if matched := s.match(changes); len(matched) > 0 {
+			s.fn(matched)
+		}
+	}
+}
+
+func (s *subscriber) match(changes []Change) []Change {
+	if len(s.keys) == 0 {
+		return changes
+	}
+	var matched []Change
+	for _, c := range changes {
+		for _, k := range s.keys {
+			if c.Key == k || (strings.HasSuffix(k, "*") && strings.HasPrefix(c.Key, k[:len(k)-1])) ||
+				(strings.HasSuffix(k, ".") && strings.HasPrefix(c.Key, k)) {
+				matched = append(matched, c)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func diff(old, new map[string]string) []Change {
+	var changes []Change
+	for k, v := range new {
+		if o, ok := old[k]; !ok || o != v {
+			changes = append(changes, Change{Key: k, Old: o, New: v})
+		}
+	}
+	for k, o := range old {
+		if _, ok := new[k]; !ok {
+			changes = append(changes, Change{Key: k, Old: o})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
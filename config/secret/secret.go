@@ -0,0 +1,136 @@
+/*
+配置文件中的加密值，格式为ENC(BASE64)，使用主密钥以AES-CTR加密并附带HMAC-SHA256校验，
+主密钥为16、24或32字节的16进制字符串，由环境变量FRAMEWORK_MASTER_KEY或
+FRAMEWORK_MASTER_KEY_FILE指定的文件提供
+*/
+package secret
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"xianhetian.com/framework/algorithm/aes"
+	"xianhetian.com/framework/algorithm/base64"
+)
+
+const (
+	EnvKey     = "FRAMEWORK_MASTER_KEY"      // 主密钥
+	EnvKeyFile = "FRAMEWORK_MASTER_KEY_FILE" // 保存主密钥的文件
+)
+
+const (
+	prefix = "ENC("
+	suffix = ")"
+	ivLen  = 16
+	macLen = sha256.Size
+)
+
+var (
+	ErrNoKey      = errors.New("secret: master key not set, use " + EnvKey + " or " + EnvKeyFile)
+	ErrInvalidKey = errors.New("secret: master key must be 16, 24 or 32 bytes in hex")
+	ErrDecrypt    = errors.New("secret: wrong master key or corrupted value")
+)
+
+// 值是否为ENC(...)格式
+func IsEncrypted(v string) bool {
+	v = strings.TrimSpace(v)
+	return strings.HasPrefix(v, prefix) && strings.HasSuffix(v, suffix)
+}
+
+// 生成一个32字节的随机主密钥，返回16进制字符串
+func GenerateKey() (string, error) {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(k), nil
+}
+
+// 解析16进制的主密钥
+func ParseKey(s string) ([]byte, error) {
+	k, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	switch len(k) {
+	case 16, 24, 32:
+		return k, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// 从环境变量读取主密钥，均未设置时返回ErrNoKey
+func KeyFromEnv() ([]byte, error) {
+	if s := os.Getenv(EnvKey); s != "" {
+		return ParseKey(s)
+	}
+	if path := os.Getenv(EnvKeyFile); path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("secret: read %s: %v", EnvKeyFile, err)
+		}
+		return ParseKey(string(b))
+	}
+	return nil, ErrNoKey
+}
+
+// 加密明文，返回ENC(...)格式的值
+func Encrypt(key []byte, plain string) (string, error) {
+	iv := make([]byte, ivLen)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	c, err := aes.NewAES(aes.ModeCtr, key, iv)
+	if err != nil {
+		return "", err
+	}
+	data, err := c.Encrypt([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	data = append(iv, data...)
+	data = append(data, mac(key, data)...)
+	return prefix + base64.Encode(data) + suffix, nil
+}
+
+// 解密ENC(...)格式的值，不是该格式时原样返回
+func Decrypt(key []byte, v string) (string, error) {
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+	if key == nil {
+		return "", ErrNoKey
+	}
+	v = strings.TrimSpace(v)
+	data, err := base64.Decode(v[len(prefix) : len(v)-len(suffix)])
+	if err != nil || len(data) < ivLen+macLen {
+		return "", ErrDecrypt
+	}
+	body, sum := data[:len(data)-macLen], data[len(data)-macLen:]
+	if !hmac.Equal(sum, mac(key, body)) {
+		return "", ErrDecrypt
+	}
+	c, err := aes.NewAES(aes.ModeCtr, key, body[:ivLen])
+	if err != nil {
+		return "", err
+	}
+	plain, err := c.Decrypt(body[ivLen:])
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// 校验值使用由主密钥派生的独立密钥，避免与加密共用
+func mac(key, data []byte) []byte {
+	k := sha256.Sum256(append([]byte("framework-config-mac:"), key...))
+	m := hmac.New(sha256.New, k[:])
+	m.Write(data)
+	return m.Sum(nil)
+}
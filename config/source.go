@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"sort"
+	"strings"
+	"xianhetian.com/framework/config/secret"
+)
+
+// 配置来源，按优先级从低到高
+const (
+	SourceDefault = "default" // SetDefault设置的默认值
+	SourceFile    = "file"    // 配置文件，记录为file:路径
+	SourceEnv     = "env"     // 环境变量，记录为env:变量名
+	SourceFlag    = "flag"    // 命令行参数--set
+	SourceRuntime = "runtime" // Set设置的值
+)
+
+const (
+	layerDefault = iota
+	layerFile
+	layerEnv
+	layerFlag
+	layerRuntime
+	layerCount
+)
+
+// 环境变量前缀，如FRAMEWORK_HTTP_TIMEOUT对应http_timeout
+const EnvPrefix = "FRAMEWORK_"
+
+type item struct {
+	value  string
+	source string
+	secret bool // 是否由ENC(...)解密得到
+}
+
+// 生效的配置项及其来源
+type Entry struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool // 是否由ENC(...)解密得到，输出时应隐藏Value
+}
+
+// 设置默认值，优先级最低，配置文件、环境变量等未设置该项时生效
+func (p *Store) SetDefault(k, v string) {
+	p.load(layerDefault, map[string]item{k: {value: v, source: SourceDefault}})
+}
+
+/*
+加载以prefix开头的环境变量，去掉前缀并转为小写作为键，双下划线表示段分隔符，
+如FRAMEWORK_HTTP_TIMEOUT对应http_timeout，FRAMEWORK_DB__HOST对应db.host；
+ENC(...)格式的值解密失败时返回错误，其它变量仍然加载
+*/
+func (p *Store) LoadEnv(prefix string) error {
+	items := make(map[string]item)
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) {
+			continue
+		}
+		name := kv[:i]
+		key := EnvKey(prefix, name)
+		if key == "" || name == EnvPrefix+"CONFIG" || name == secret.EnvKey || name == secret.EnvKeyFile {
+			continue
+		}
+		items[key] = item{value: kv[i+1:], source: SourceEnv + ":" + name}
+	}
+	err := p.decrypt(items)
+	p.load(layerEnv, items)
+	return err
+}
+
+// 返回环境变量对应的键
+func EnvKey(prefix, name string) string {
+	return strings.ToLower(strings.Replace(strings.TrimPrefix(name, prefix), "__", ".", -1))
+}
+
+/*
+加载命令行参数中的--set key=value（也可写作-set key=value、--set=key=value），可出现多次；
+其它参数忽略，不影响应用自身的参数解析；ENC(...)格式的值解密失败时返回错误，其它参数仍然加载
+*/
+func (p *Store) LoadArgs(args []string) error {
+	items := make(map[string]item)
+	for i := 0; i < len(args); i++ {
+		name, value, ok := splitArg(args[i])
+		if name != "set" {
+			continue
+		}
+		if !ok {
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			value = args[i]
+		}
+		if k, v, ok := splitKV(value); ok {
+			items[k] = item{value: v, source: SourceFlag}
+		}
+	}
+	err := p.decrypt(items)
+	p.load(layerFlag, items)
+	return err
+}
+
+/*
+在fs上注册-set参数，解析时写入命令行参数层，用于应用自行调用flag.Parse的场景；
+默认配置加载时已从os.Args读取过--set，重复设置结果相同
+*/
+func (p *Store) Flags(fs *flag.FlagSet) {
+	fs.Var((*setFlag)(p), "set", "override a config value, as key=value (repeatable)")
+}
+
+type setFlag Store
+
+func (f *setFlag) String() string {
+	return ""
+}
+
+func (f *setFlag) Set(s string) error {
+	k, v, ok := splitKV(s)
+	if !ok {
+		return errors.New("expected key=value")
+	}
+	items := map[string]item{k: {value: v, source: SourceFlag}}
+	if err := (*Store)(f).decrypt(items); err != nil {
+		return err
+	}
+	(*Store)(f).load(layerFlag, items)
+	return nil
+}
+
+// 返回配置项的来源，不存在时返回空字符串
+func (p *Store) Source(key string) string {
+	return p.current().sources[key]
+}
+
+// 返回所有生效的配置项及其来源，按键排序
+func (p *Store) Entries() []Entry {
+	s := p.current()
+	entries := make([]Entry, 0, len(s.values))
+	for k, v := range s.values {
+		entries = append(entries, Entry{Key: k, Value: v, Source: s.sources[k], Secret: s.secrets[k]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// 写入指定来源层并重新合并
+func (p *Store) load(layer int, items map[string]item) {
+	p.ensure()
+	p.mu.Lock()
+	if p.layers[layer] == nil {
+		p.layers[layer] = make(map[string]item)
+	}
+	for k, it := range items {
+		p.layers[layer][k] = it
+	}
+	changes := p.merge()
+	p.mu.Unlock()
+	p.notify(changes)
+}
+
+/*
+读取配置文件并合并到文件层，后加载的文件优先，文件格式由扩展名决定，见ReadFile；
+ENC(...)格式的值解密失败时返回错误，文件中的其它配置项仍然加载
+*/
+func (p *Store) LoadFile(path string) error {
+	vals, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	items := fileItems(path, vals)
+	err = p.decrypt(items)
+	p.ensure()
+	p.mu.Lock()
+	p.files = append(p.files, path)
+	p.mu.Unlock()
+	p.load(layerFile, items)
+	return err
+}
+
+func fileItems(path string, vals map[string]string) map[string]item {
+	items := make(map[string]item, len(vals))
+	for k, v := range vals {
+		items[k] = item{value: v, source: SourceFile + ":" + path}
+	}
+	return items
+}
+
+// 合并各层生成新的快照并替换，返回变化的配置项，调用时须持有p.mu
+func (p *Store) merge() []Change {
+	s := &snapshot{values: make(map[string]string), sources: make(map[string]string), secrets: make(map[string]bool)}
+	for _, l := range p.layers {
+		for k, it := range l {
+			s.values[k] = it.value
+			s.sources[k] = it.source
+			s.secrets[k] = it.secret
+		}
+	}
+	old := p.loaded()
+	p.snap.Store(s)
+	return diff(old.values, s.values)
+}
+
+// 返回--framework-config指定的配置文件路径，不使用--config，避免与应用自身的参数冲突
+func argConfig(args []string) string {
+	for i := 0; i < len(args); i++ {
+		name, value, ok := splitArg(args[i])
+		if name != "framework-config" {
+			continue
+		}
+		if ok {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// 拆分-name、--name、-name=value、--name=value
+func splitArg(arg string) (name, value string, ok bool) {
+	if !strings.HasPrefix(arg, "-") {
+		return "", "", false
+	}
+	arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+	if i := strings.Index(arg, "="); i >= 0 {
+		return arg[:i], arg[i+1:], true
+	}
+	return arg, "", false
+}
+
+func splitKV(s string) (string, string, bool) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// 读取或解析配置项失败
+type KeyError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *KeyError) Error() string {
+	if e.Err == ErrNotFound {
+		return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("config: %s=%q: %v", e.Key, e.Value, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// 返回配置项的值及是否存在
+func (p *Store) Lookup(key string) (string, bool) {
+	v, ok := p.current().values[key]
+	return v, ok
+}
+
+// 返回所有配置项的键，按字典序排列
+func (p *Store) Keys() []string {
+	values := p.current().values
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 返回段内的配置项，键去掉"段名."前缀；段名可含"."，如http.client
+func (p *Store) Section(name string) map[string]string {
+	prefix := name + "."
+	m := make(map[string]string)
+	for k, v := range p.current().values {
+		if strings.HasPrefix(k, prefix) {
+			m[k[len(prefix):]] = v
+		}
+	}
+	return m
+}
+
+// 返回配置项的值；GetXxx在配置项不存在时返回的*KeyError包装ErrNotFound，解析失败时返回*KeyError
+func (p *Store) GetStr(key string) (string, error) {
+	v, ok := p.Lookup(key)
+	if !ok {
+		return "", &KeyError{Key: key, Err: ErrNotFound}
+	}
+	return v, nil
+}
+
+func (p *Store) GetInt(key string) (int, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(v)
+	return i, wrap(key, v, err)
+}
+
+func (p *Store) GetInt64(key string) (int64, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	return i, wrap(key, v, err)
+}
+
+func (p *Store) GetFloat(key string) (float64, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, wrap(key, v, err)
+}
+
+func (p *Store) GetBool(key string) (bool, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return false, err
+	}
+	b, err := ParseBool(v)
+	return b, wrap(key, v, err)
+}
+
+func (p *Store) GetDuration(key string) (time.Duration, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return 0, err
+	}
+	d, err := ParseDuration(v)
+	return d, wrap(key, v, err)
+}
+
+func (p *Store) GetSize(key string) (int64, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := ParseSize(v)
+	return n, wrap(key, v, err)
+}
+
+func (p *Store) GetList(key string) ([]string, error) {
+	v, err := p.GetStr(key)
+	if err != nil {
+		return nil, err
+	}
+	return ParseList(v), nil
+}
+
+func (p *Store) Duration(key string) time.Duration {
+	d, _ := ParseDuration(p.Get(key))
+	return d
+}
+
+func (p *Store) DefaultDuration(key string, defaultVal string) time.Duration {
+	result := defaultVal
+	if v := p.Get(key); v != "" {
+		result = v
+	}
+	d, _ := ParseDuration(result)
+	return d
+}
+
+func (p *Store) Size(key string) int64 {
+	n, _ := ParseSize(p.Get(key))
+	return n
+}
+
+func (p *Store) DefaultSize(key string, defaultVal string) int64 {
+	result := defaultVal
+	if v := p.Get(key); v != "" {
+		result = v
+	}
+	n, _ := ParseSize(result)
+	return n
+}
+
+func (p *Store) List(key string) []string {
+	return ParseList(p.Get(key))
+}
+
+func (p *Store) DefaultList(key string, defaultVal string) []string {
+	if v := p.Get(key); v != "" {
+		return ParseList(v)
+	}
+	return ParseList(defaultVal)
+}
+
+func wrap(key, value string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &KeyError{Key: key, Value: value, Err: err}
+}
+
+// 解析布尔值，除strconv.ParseBool支持的格式外，还支持yes/no、on/off
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "on", "y":
+		return true, nil
+	case "no", "off", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
+
+/*
+解析时间间隔，支持time.ParseDuration的格式（如"30s"、"1h30m"）及天数（如"7d"）；
+不带单位的整数按毫秒处理，与http_timeout等已有配置项一致
+*/
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+var sizeUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+	"t":  1 << 40,
+	"tb": 1 << 40,
+}
+
+// 解析字节数，如"512"、"64KB"、"10MB"、"1.5G"，单位不区分大小写，按1024进位，KiB等写法同KB
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) })
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = strings.TrimSpace(s[:i]), strings.ToLower(s[i:])
+	}
+	unit = strings.Replace(unit, "ib", "b", 1)
+	m, ok := sizeUnits[unit]
+	if !ok || num == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		return n * m, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * float64(m)), nil
+}
+
+// 解析以逗号分隔的列表，去除各项首尾空白并忽略空项
+func ParseList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+/*
+将配置填充到结构体，v须为结构体指针，字段标签：
+config:"key[,size]" 键名，为空时使用字段名的下划线形式（如MaxConns为max_conns），"-"表示忽略，",size"表示按字节数解析
+default:"value"     配置项不存在时使用的值
+required:"true"     配置项不存在且没有默认值时返回错误
+支持string、bool、整数、浮点数、time.Duration、[]string（逗号分隔）及嵌套结构体，
+嵌套结构体的键为"键名.字段键"
+*/
+func (p *Store) Unmarshal(v interface{}) error {
+	return p.UnmarshalSection("", v)
+}
+
+// 同Unmarshal，只使用段内的配置项，如section为http时字段timeout对应http.timeout
+func (p *Store) UnmarshalSection(section string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Unmarshal requires a non-nil struct pointer")
+	}
+	var missing []string
+	if err := p.unmarshal(section, rv.Elem(), &missing); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("config: missing required keys: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (p *Store) unmarshal(prefix string, rv reflect.Value, missing *[]string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opt := f.Tag.Get("config"), ""
+		if j := strings.Index(name, ","); j >= 0 {
+			name, opt = name[:j], name[j+1:]
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = snake(f.Name)
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Struct && f.Type != durationType {
+			if err := p.unmarshal(key, fv, missing); err != nil {
+				return err
+			}
+			continue
+		}
+		val, ok := p.Lookup(key)
+		if !ok {
+			val, ok = f.Tag.Lookup("default")
+		}
+		if !ok {
+			if f.Tag.Get("required") == "true" {
+				*missing = append(*missing, key)
+			}
+			continue
+		}
+		if err := setField(fv, val, opt == "size"); err != nil {
+			return &KeyError{Key: key, Value: val, Err: err}
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, val string, size bool) error {
+	if fv.Type() == durationType {
+		d, err := ParseDuration(val)
+		if err == nil {
+			fv.SetInt(int64(d))
+		}
+		return err
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		var err error
+		if size {
+			n, err = ParseSize(val)
+		} else {
+			n, err = strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		}
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("value overflows %s", fv.Type())
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if size {
+			s, err := ParseSize(val)
+			if err != nil {
+				return err
+			}
+			n = uint64(s)
+		} else {
+			var err error
+			if n, err = strconv.ParseUint(strings.TrimSpace(val), 10, 64); err != nil {
+				return err
+			}
+		}
+		if fv.OverflowUint(n) {
+			return fmt.Errorf("value overflows %s", fv.Type())
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		fv.Set(reflect.ValueOf(ParseList(val)))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// 将驼峰命名转为下划线形式，连续大写视为一个单词，如HTTPTimeout为http_timeout
+func snake(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]) && unicode.IsUpper(rs[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,354 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"xianhetian.com/framework/logger"
+)
+
+const (
+	RoundRobin   Strategy = iota // 轮询
+	LeastPending                 // 选择进行中请求数最少的节点
+	Weighted                     // 按权重平滑轮询
+)
+
+var ErrNoEndpoint = errors.New("http: balancer has no endpoint")
+
+// 负载均衡策略
+type Strategy int
+
+func (s Strategy) String() string {
+	switch s {
+	case RoundRobin:
+		return "ROUND_ROBIN"
+	case LeastPending:
+		return "LEAST_PENDING"
+	case Weighted:
+		return "WEIGHTED"
+	}
+	return fmt.Sprintf("UNKNOWN STRATEGY %d", int(s))
+}
+
+// 后端节点
+type Endpoint struct {
+	URL    string // 基础地址，如http://10.0.0.1:8080/api
+	Weight int    // 权重，Weighted策略使用，默认1
+}
+
+// 负载均衡配置
+type BalancerConfig struct {
+	Endpoints     []Endpoint    // 后端节点
+	Strategy      Strategy      // 负载均衡策略
+	MaxFailures   int           // 连续失败达到该次数时摘除节点，默认3
+	ProbeInterval time.Duration // 摘除节点的探测间隔，默认10秒
+	// 探测路径，不为空时定期GET该路径，返回2xx后恢复节点；为空时摘除满ProbeInterval后直接恢复
+	ProbePath string
+	// 单个请求最多尝试的节点数，默认为节点总数；只对幂等方法或带有幂等键、且请求体可以重新编码的请求换节点重试；
+	// 每个节点只发送一次，不使用client的重试策略
+	Attempts int
+}
+
+// 节点状态
+type EndpointStatus struct {
+	URL      string
+	Healthy  bool  // 是否可用
+	Pending  int64 // 进行中的请求数
+	Failures int   // 连续失败次数
+}
+
+type endpoint struct {
+	url     string
+	weight  int
+	current int // 平滑加权轮询的当前权重
+	pending int64
+
+	mu        sync.Mutex
+	failures  int
+	ejected   bool
+	ejectedAt time.Time
+}
+
+// 客户端负载均衡，将请求分发到多个后端节点，失败时摘除节点并换节点重试
+type Balancer struct {
+	client    *httpClient
+	cfg       BalancerConfig
+	endpoints []*endpoint
+	next      uint32
+	mu        sync.Mutex // 保护加权轮询的current
+	stop      chan struct{}
+	once      sync.Once
+}
+
+// 返回一个使用client发送请求的负载均衡器，不再使用时调用Close停止探测
+func NewBalancer(client *httpClient, cfg BalancerConfig) (*Balancer, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = 10 * time.Second
+	}
+	if cfg.Attempts <= 0 || cfg.Attempts > len(cfg.Endpoints) {
+		cfg.Attempts = len(cfg.Endpoints)
+	}
+	b := &Balancer{client: client, cfg: cfg, stop: make(chan struct{})}
+	for _, e := range cfg.Endpoints {
+		if e.URL == "" {
+			return nil, errors.New("http: balancer endpoint url is empty")
+		}
+		if e.Weight <= 0 {
+			e.Weight = 1
+		}
+		b.endpoints = append(b.endpoints, &endpoint{url: strings.TrimRight(e.URL, "/"), weight: e.Weight})
+	}
+	go b.probe()
+	return b, nil
+}
+
+// 停止探测
+func (b *Balancer) Close() {
+	b.once.Do(func() { close(b.stop) })
+}
+
+// 返回各节点的状态
+func (b *Balancer) Endpoints() []EndpointStatus {
+	s := make([]EndpointStatus, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		e.mu.Lock()
+		s = append(s, EndpointStatus{URL: e.url, Healthy: !e.ejected, Pending: atomic.LoadInt64(&e.pending), Failures: e.failures})
+		e.mu.Unlock()
+	}
+	return s
+}
+
+/*
+发送请求，Request.Url为相对于节点基础地址的路径（可带查询参数），Method为空时为GET
+网络错误或5xx视为节点失败，允许时换一个未尝试过的节点重试
+*/
+func (b *Balancer) Do(ctx context.Context, r *Request) (*Response, error) {
+	if r.Method == "" {
+		r.setMethod(http.MethodGet)
+	}
+	return b.service(ctx, r)
+}
+
+// GET请求方法
+func (b *Balancer) Get(r *Request) (*Response, error) {
+	r.setMethod(http.MethodGet)
+	return b.service(context.Background(), r)
+}
+
+// POST请求方法
+func (b *Balancer) Post(r *Request) (*Response, error) {
+	r.setMethod(http.MethodPost)
+	return b.service(context.Background(), r)
+}
+
+// PUT请求方法
+func (b *Balancer) Put(r *Request) (*Response, error) {
+	r.setMethod(http.MethodPut)
+	return b.service(context.Background(), r)
+}
+
+// PATCH请求方法
+func (b *Balancer) Patch(r *Request) (*Response, error) {
+	r.setMethod(http.MethodPatch)
+	return b.service(context.Background(), r)
+}
+
+// DELETE请求方法
+func (b *Balancer) Delete(r *Request) (*Response, error) {
+	r.setMethod(http.MethodDelete)
+	return b.service(context.Background(), r)
+}
+
+// HEAD请求方法，响应体为空
+func (b *Balancer) Head(r *Request) (*Response, error) {
+	r.setMethod(http.MethodHead)
+	return b.service(context.Background(), r)
+}
+
+func (b *Balancer) service(ctx context.Context, r *Request) (*Response, error) {
+	attempts := 1
+	if r.replayable() {
+		attempts = b.cfg.Attempts
+	}
+	tried := make(map[*endpoint]bool, attempts)
+	var (
+		res *Response
+		err error
+	)
+	for i := 0; i < attempts; i++ {
+		e := b.pick(tried)
+		tried[e] = true
+		req := *r
+		req.Url = join(e.url, r.Url)
+		atomic.AddInt64(&e.pending, 1)
+		res, err = b.client.exchange(ctx, &req, false)
+		atomic.AddInt64(&e.pending, -1)
+		if ctx.Err() != nil {
+			return res, err
+		}
+		if err == nil && res.StatusCode < http.StatusInternalServerError {
+			e.success()
+			return res, nil
+		}
+		// 熔断器打开不代表节点故障，只换节点
+		if err != ErrCircuitOpen {
+			e.failure(b.cfg.MaxFailures)
+		}
+	}
+	return res, err
+}
+
+// 选择一个未尝试过的节点，优先可用节点，全部摘除时仍在所有节点中选择
+func (b *Balancer) pick(tried map[*endpoint]bool) *endpoint {
+	var candidates []*endpoint
+	for _, e := range b.endpoints {
+		if !tried[e] && e.healthy() {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, e := range b.endpoints {
+			if !tried[e] {
+				candidates = append(candidates, e)
+			}
+		}
+	}
+	start := int(atomic.AddUint32(&b.next, 1)-1) % len(candidates)
+	switch b.cfg.Strategy {
+	case LeastPending:
+		best := candidates[start]
+		for i := 1; i < len(candidates); i++ {
+			e := candidates[(start+i)%len(candidates)]
+			if atomic.LoadInt64(&e.pending) < atomic.LoadInt64(&best.pending) {
+				best = e
+			}
+		}
+		return best
+	case Weighted:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		var best *endpoint
+		total := 0
+		for _, e := range candidates {
+			e.current += e.weight
+			total += e.weight
+			if best == nil || e.current > best.current {
+				best = e
+			}
+		}
+		best.current -= total
+		return best
+	}
+	return candidates[start]
+}
+
+// 定期恢复摘除的节点
+func (b *Balancer) probe() {
+	ticker := time.NewTicker(b.cfg.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, e := range b.endpoints {
+			e.mu.Lock()
+			due := e.ejected && time.Since(e.ejectedAt) >= b.cfg.ProbeInterval
+			e.mu.Unlock()
+			if !due {
+				continue
+			}
+			if b.cfg.ProbePath != "" && !b.check(e) {
+				e.mu.Lock()
+				e.ejectedAt = time.Now()
+				e.mu.Unlock()
+				continue
+			}
+			e.mu.Lock()
+			e.ejected, e.failures = false, 0
+			e.mu.Unlock()
+			logger.Info("负载均衡节点恢复：%v", e.url)
+		}
+	}
+}
+
+func (b *Balancer) check(e *endpoint) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.ProbeInterval)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, join(e.url, b.cfg.ProbePath), nil)
+	if err != nil {
+		return false
+	}
+	res, err := b.client.client.Do(req)
+	if err != nil {
+		return false
+	}
+	discard(res)
+	return res.StatusCode >= 200 && res.StatusCode < 300
+}
+
+func (e *endpoint) healthy() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !e.ejected
+}
+
+func (e *endpoint) success() {
+	e.mu.Lock()
+	e.failures = 0
+	e.mu.Unlock()
+}
+
+func (e *endpoint) failure(max int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if !e.ejected && e.failures >= max {
+		e.ejected, e.ejectedAt = true, time.Now()
+		logger.Error("负载均衡节点摘除：%v", e.url)
+	}
+}
+
+// 请求是否可以发往另一个节点：方法幂等或带有幂等键，且请求体可以重新编码
+func (r *Request) replayable() bool {
+	switch d := r.Data.(type) {
+	case Multipart:
+		return (&Request{Method: r.Method, IdempotencyKey: r.IdempotencyKey, Data: &d}).replayable()
+	case *Multipart:
+		for _, f := range d.Files {
+			if f.Reader != nil {
+				return false
+			}
+		}
+	case io.Reader:
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return r.IdempotencyKey != "" || r.Header[HeaderIdempotencyKey] != ""
+}
+
+func join(base, path string) string {
+	if path == "" {
+		return base
+	}
+	if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "?") {
+		path = "/" + path
+	}
+	return base + path
+}
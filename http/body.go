@@ -0,0 +1,118 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	ContentTypeJSON      = "application/json; charset=utf-8"
+	ContentTypeForm      = "application/x-www-form-urlencoded"
+	ContentTypeOctet     = "application/octet-stream"
+	ContentTypeMultipart = "multipart/form-data"
+)
+
+// multipart/form-data请求数据
+type Multipart struct {
+	Fields map[string]string // 普通表单字段
+	Files  []FilePart        // 文件字段
+}
+
+// multipart/form-data中的文件
+type FilePart struct {
+	Field       string    // 表单字段名
+	FileName    string    // 文件名
+	ContentType string    // 文件类型，默认application/octet-stream
+	Data        []byte    // 文件内容
+	Reader      io.Reader // 文件内容，不为nil时优先于Data
+}
+
+/*
+根据Request.Data的类型编码请求体
+string 原样发送
+[]byte 按application/octet-stream发送
+map[string]string、url.Values 按application/x-www-form-urlencoded编码
+Multipart、*Multipart 按multipart/form-data编码
+io.Reader 流式发送，按application/octet-stream发送
+其它类型 按application/json编码
+*/
+func encodeBody(data interface{}) (body io.Reader, contentType string, err error) {
+	switch d := data.(type) {
+	case nil:
+		return nil, "", nil
+	case string:
+		return strings.NewReader(d), "", nil
+	case []byte:
+		return bytes.NewReader(d), ContentTypeOctet, nil
+	case map[string]string:
+		values := url.Values{}
+		for k, v := range d {
+			values.Set(k, v)
+		}
+		return strings.NewReader(values.Encode()), ContentTypeForm, nil
+	case url.Values:
+		return strings.NewReader(d.Encode()), ContentTypeForm, nil
+	case Multipart:
+		return d.encode()
+	case *Multipart:
+		return d.encode()
+	case io.Reader:
+		return d, ContentTypeOctet, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return bytes.NewReader(b), ContentTypeJSON, nil
+}
+
+func (m *Multipart) encode() (io.Reader, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range m.Fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, f := range m.Files {
+		if err := f.write(w); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+func (f *FilePart) write(w *multipart.Writer) error {
+	ct := f.ContentType
+	if ct == "" {
+		ct = ContentTypeOctet
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(f.Field), escapeQuotes(f.FileName)))
+	h.Set("Content-Type", ct)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if f.Reader != nil {
+		_, err = io.Copy(part, f.Reader)
+		return err
+	}
+	_, err = part.Write(f.Data)
+	return err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	cf "xianhetian.com/framework/config"
+	"xianhetian.com/framework/logger"
+)
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+var ErrCircuitOpen = errors.New("http: circuit breaker is open")
+
+type BreakerState int
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "CLOSED"
+	case BreakerOpen:
+		return "OPEN"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	}
+	return fmt.Sprintf("UNKNOWN STATE %d", int(s))
+}
+
+// 熔断器配置，每个Host独立统计
+type BreakerConfig struct {
+	Window       time.Duration // 统计窗口，窗口结束后清零计数
+	MinRequests  int           // 窗口内请求数达到该值才判断失败比例
+	FailureRatio float64       // 失败比例达到该值时熔断，取值0~1
+	CoolDown     time.Duration // 熔断后经过该时间进入半开状态
+	HalfOpenMax  int           // 半开状态允许同时发出的试探请求数，全部成功后恢复
+}
+
+// 返回根据framework.conf配置生成的默认熔断器配置
+func DefaultBreakerConfig() *BreakerConfig {
+	return &BreakerConfig{
+		Window:       time.Duration(cf.Config.DefaultInt("http_breaker_window", "10000")) * time.Millisecond,
+		MinRequests:  cf.Config.DefaultInt("http_breaker_min_requests", "10"),
+		FailureRatio: cf.Config.DefaultFloat("http_breaker_failure_ratio", "0.5"),
+		CoolDown:     time.Duration(cf.Config.DefaultInt("http_breaker_cool_down", "30000")) * time.Millisecond,
+		HalfOpenMax:  cf.Config.DefaultInt("http_breaker_half_open_max", "1"),
+	}
+}
+
+type breaker struct {
+	host      string
+	cfg       *BreakerConfig
+	mu        sync.Mutex
+	state     BreakerState
+	total     int
+	failures  int
+	start     time.Time // 当前统计窗口的开始时间
+	openedAt  time.Time
+	trials    int // 半开状态已发出的试探请求数
+	successes int // 半开状态成功的试探请求数
+}
+
+type breakers struct {
+	cfg   *BreakerConfig
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+func newBreakers(cfg *BreakerConfig) *breakers {
+	return &breakers{cfg: cfg, hosts: make(map[string]*breaker)}
+}
+
+func (bs *breakers) get(host string) *breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.hosts[host]
+	if !ok {
+		b = &breaker{host: host, cfg: bs.cfg, start: time.Now()}
+		bs.hosts[host] = b
+	}
+	return b
+}
+
+// 返回指定Host的熔断器状态
+func (bs *breakers) state(host string) BreakerState {
+	b := bs.get(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// 判断请求是否允许发出
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.CoolDown {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trials >= b.halfOpenMax() {
+			return ErrCircuitOpen
+		}
+		b.trials++
+		return nil
+	}
+	if b.cfg.Window > 0 && now.Sub(b.start) > b.cfg.Window {
+		b.reset(now)
+	}
+	return nil
+}
+
+// 请求被调用方取消，不记录结果，归还半开状态下allow占用的试探名额
+func (b *breaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen && b.trials > 0 {
+		b.trials--
+	}
+}
+
+// 记录请求结果
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		if !success {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.halfOpenMax() {
+			b.reset(time.Now())
+			b.setState(BreakerClosed)
+		}
+		return
+	case BreakerOpen:
+		return
+	}
+	b.total++
+	if !success {
+		b.failures++
+	}
+	if b.total >= b.cfg.MinRequests && float64(b.failures)/float64(b.total) >= b.cfg.FailureRatio {
+		b.open()
+	}
+}
+
+func (b *breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(BreakerOpen)
+}
+
+func (b *breaker) reset(now time.Time) {
+	b.total = 0
+	b.failures = 0
+	b.start = now
+}
+
+func (b *breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	logger.Info("HTTP熔断器状态变更：%v %v -> %v", b.host, b.state.String(), state.String())
+	b.state = state
+	b.trials = 0
+	b.successes = 0
+}
+
+func (b *breaker) halfOpenMax() int {
+	if b.cfg.HalfOpenMax <= 0 {
+		return 1
+	}
+	return b.cfg.HalfOpenMax
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"xianhetian.com/framework/algorithm/zip"
+)
+
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingBrotli   = "br"
+)
+
+// 请求头Accept-Encoding的默认值
+const acceptEncoding = "gzip, deflate, br"
+
+// 压缩请求体并透明解压响应体的Transport
+type encodingTransport struct {
+	next      http.RoundTripper
+	encoding  string // 请求体压缩方式，identity为不压缩
+	threshold int64  // 请求体达到该长度才压缩
+}
+
+func (t *encodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.compress(req); err != nil {
+		return nil, err
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = decompress(res); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// 请求体长度已知且达到阈值时压缩，并设置Content-Encoding
+func (t *encodingTransport) compress(req *http.Request) error {
+	if t.encoding == "" || t.encoding == EncodingIdentity || req.GetBody == nil ||
+		req.ContentLength < t.threshold || req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	switch t.encoding {
+	case EncodingGzip:
+		data, err = zip.Gzip(data)
+	case EncodingDeflate:
+		data, err = zip.Deflate(data)
+	case EncodingBrotli:
+		data, err = zip.Brotli(data)
+	default:
+		return fmt.Errorf("http: unsupported content encoding %s", t.encoding)
+	}
+	if err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Encoding", t.encoding)
+	return nil
+}
+
+// 按Content-Encoding解压响应体
+func decompress(res *http.Response) error {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case EncodingGzip:
+		r, err = gzip.NewReader(res.Body)
+	case EncodingDeflate:
+		r, err = zlib.NewReader(res.Body)
+	case EncodingBrotli:
+		r = brotli.NewReader(res.Body)
+	default:
+		return nil
+	}
+	if err != nil {
+		// HEAD或空响应体没有可解压的数据
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	res.Body = &decompressBody{Reader: r, body: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return nil
+}
+
+type decompressBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (d *decompressBody) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return d.body.Close()
+}
@@ -1,56 +1,181 @@
 package http
 
 import (
-	"bytes"
-	"fmt"
+	"context"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"strconv"
+	"sync/atomic"
 	"time"
 	cf "xianhetian.com/framework/config"
+	"xianhetian.com/framework/logger"
 )
 
 var (
 	ht   = cf.Config.DefaultInt("http_timeout", "10000")                // 超时时间
 	htc  = cf.Config.DefaultInt("http_retry_count", "3")                // 重试次数
-	hit  = cf.Config.DefaultInt("http_interval_time", "0")              // 间隔时间
-	conn = cf.Config.DefaultString("http_connection", "close")          // 设置close则为短连接每一次请求都关闭链接，设置keep-alive则为长连接
-	ka   = cf.Config.DefaultString("http_keepalive", "60")              // 设置长连接过期时间
-	ce   = cf.Config.DefaultString("http_content_encoding", "identity") // 压缩模式,设置后浏览器自动处理解压，默认identity
+	hit  = cf.Config.DefaultInt("http_interval_time", "100")            // 首次重试间隔时间，之后指数增长
+	hmd  = cf.Config.DefaultInt("http_retry_max_delay", "5000")         // 重试间隔时间上限
+	conn = cf.Config.DefaultString("http_connection", "keep-alive")     // 设置close则为短连接每一次请求都关闭链接，设置keep-alive则复用连接
+	ka   = cf.Config.DefaultInt("http_keepalive", "60")                 // 空闲连接过期时间，单位：秒
+	ce   = cf.Config.DefaultString("http_content_encoding", "identity") // 请求体压缩方式：identity、gzip、deflate、br，默认identity
+	hct  = cf.Config.DefaultInt("http_compress_threshold", "1024")      // 请求体达到该字节数才压缩
+	hb   = cf.Config.DefaultBool("http_breaker", "false")               // 是否开启熔断器
 )
 
+// 配置项http_timeout的当前值，配置重新加载时更新
+var liveTimeout = int64(time.Duration(ht) * time.Millisecond)
+
+func init() {
+	cf.Config.OnChange("http_timeout", func(_, v string) {
+		if v == "" {
+			v = "10000"
+		}
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			logger.Error("配置项http_timeout无效：%v", v)
+			return
+		}
+		atomic.StoreInt64(&liveTimeout, int64(time.Duration(ms)*time.Millisecond))
+	})
+}
+
 type httpClient struct {
-	client       *http.Client
-	retryCount   int           // 重试次数
-	intervalTime time.Duration // 间隔时间
+	client      *http.Client
+	transport   http.RoundTripper // 中间件之下的底层Transport
+	middlewares []Middleware      // 中间件，第一个位于最外层
+	retry       *RetryPolicy      // 重试策略
+	breakers    *breakers         // 按Host划分的熔断器，为nil时不熔断
+	live        bool              // 是否使用http_timeout的当前值作为超时时间
 }
 
 type Request struct {
-	Url    string            // 传输URL
-	Method string            // 请求方法
-	Data   interface{}       // 传输数据
-	Header map[string]string // HTTP请求头
+	Url         string            // 传输URL
+	Method      string            // 请求方法
+	Data        interface{}       // 传输数据，编码方式见encodeBody
+	Header      map[string]string // HTTP请求头
+	ContentType string            // 请求体类型，为空时根据Data的类型确定
+	// 幂等键，设置后以Idempotency-Key请求头发送，非幂等方法（如POST）也允许重试
+	IdempotencyKey string
+	// 单次发送的超时时间，大于0时覆盖http_timeout；总耗时（含重试）由Do的ctx控制
+	Timeout time.Duration
 }
 
-// 返回一个HTTPClient的实例
+/*
+返回一个使用framework.conf配置的HTTPClient实例，超时时间随http_timeout重新加载变化；
+http_content_encoding无效时记录日志并不压缩请求体，TLS、代理配置有误时panic
+*/
 func NewHTTPClient() *httpClient {
-	return &httpClient{
-		retryCount:   htc,
-		intervalTime: time.Duration(hit) * time.Millisecond,
-		client:       &http.Client{Timeout: time.Duration(ht) * time.Millisecond},
+	o := DefaultOptions()
+	o.LiveTimeout = true
+	if !validEncoding(o.ContentEncoding) {
+		logger.Error("配置项http_content_encoding无效：%v，不压缩请求体", o.ContentEncoding)
+		o.ContentEncoding = EncodingIdentity
+	}
+	h, err := NewHTTPClientWithOptions(o)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// 根据配置返回一个HTTPClient的实例，o为nil时使用DefaultOptions并开启LiveTimeout
+func NewHTTPClientWithOptions(o *Options) (*httpClient, error) {
+	if o == nil {
+		o = DefaultOptions()
+		o.LiveTimeout = true
+	}
+	transport, err := o.transport()
+	if err != nil {
+		return nil, err
+	}
+	h := &httpClient{
+		transport: transport,
+		client:    &http.Client{Timeout: o.Timeout, Transport: transport},
+		live:      o.LiveTimeout,
+	}
+	h.SetRetryPolicy(o.Retry)
+	h.SetBreaker(o.Breaker)
+	h.Use(o.Middlewares...)
+	if o.OAuth2 != nil {
+		h.Use(OAuth2(*o.OAuth2))
+	}
+	return h, nil
+}
+
+// 追加中间件，先追加的中间件位于外层
+func (h *httpClient) Use(mws ...Middleware) {
+	h.middlewares = append(h.middlewares, mws...)
+	h.client.Transport = chain(h.transport, h.middlewares)
+}
+
+// 设置熔断器配置，cfg为nil时关闭熔断
+func (h *httpClient) SetBreaker(cfg *BreakerConfig) {
+	if cfg == nil {
+		h.breakers = nil
+		return
 	}
+	h.breakers = newBreakers(cfg)
+}
+
+// 返回指定Host的熔断器状态，未开启熔断时总是BreakerClosed
+func (h *httpClient) BreakerState(host string) BreakerState {
+	if h.breakers == nil {
+		return BreakerClosed
+	}
+	return h.breakers.state(host)
+}
+
+// 设置重试策略，p为nil时不重试
+func (h *httpClient) SetRetryPolicy(p *RetryPolicy) {
+	if p == nil {
+		p = &RetryPolicy{}
+	}
+	h.retry = p
+}
+
+// 按Request.Method（为空时为GET）发送请求，ctx取消或超时后立即返回，包括重试等待期间
+func (h *httpClient) Do(ctx context.Context, r *Request) (*Response, error) {
+	if r.Method == "" {
+		r.setMethod(http.MethodGet)
+	}
+	return h.service(ctx, r)
 }
 
 // GET请求方法
-func (h *httpClient) Get(r *Request) (string, error) {
+func (h *httpClient) Get(r *Request) (*Response, error) {
 	r.setMethod(http.MethodGet)
-	return h.service(r)
+	return h.service(context.Background(), r)
 }
 
 // POST请求方法
-func (h *httpClient) Post(r *Request) (string, error) {
+func (h *httpClient) Post(r *Request) (*Response, error) {
 	r.setMethod(http.MethodPost)
-	return h.service(r)
+	return h.service(context.Background(), r)
+}
+
+// PUT请求方法
+func (h *httpClient) Put(r *Request) (*Response, error) {
+	r.setMethod(http.MethodPut)
+	return h.service(context.Background(), r)
+}
+
+// PATCH请求方法
+func (h *httpClient) Patch(r *Request) (*Response, error) {
+	r.setMethod(http.MethodPatch)
+	return h.service(context.Background(), r)
+}
+
+// DELETE请求方法
+func (h *httpClient) Delete(r *Request) (*Response, error) {
+	r.setMethod(http.MethodDelete)
+	return h.service(context.Background(), r)
+}
+
+// HEAD请求方法，响应体为空
+func (h *httpClient) Head(r *Request) (*Response, error) {
+	r.setMethod(http.MethodHead)
+	return h.service(context.Background(), r)
 }
 
 // 设置Request的URL
@@ -73,50 +198,108 @@ func (r *Request) setMethod(method string) {
 	r.Method = method
 }
 
-func (h *httpClient) service(r *Request) (string, error) {
-	var str string
-	switch r.Data.(type) {
-	case string:
-		d, ok := r.Data.(string)
-		if ok {
-			str = d
-		}
-	case map[string]string:
-		m, _ := r.Data.(map[string]string)
-		var param bytes.Buffer
-		for k, v := range m {
-			param.WriteString(fmt.Sprintf("&%s=%s", k, v))
-		}
-		rs := []rune(param.String())
-		str = string(rs[1:])
+func (h *httpClient) service(ctx context.Context, r *Request) (*Response, error) {
+	return h.exchange(ctx, r, true)
+}
+
+// 编码并发送请求，读取响应体；retry为false时不使用重试策略，只发送一次
+func (h *httpClient) exchange(ctx context.Context, r *Request, retry bool) (*Response, error) {
+	body, contentType, err := encodeBody(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.Url, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.ContentType != "" {
+		contentType = r.ContentType
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
-	req, _ := http.NewRequest(r.Method, r.Url, strings.NewReader(str))
 	if len(r.Header) > 0 {
 		for k, v := range r.Header {
-			req.Header.Add(k, v)
+			req.Header.Set(k, v)
 		}
 	}
-	req.Header.Add("Connection", conn)
-	req.Header.Add("Keep-Alive", ka)
-	req.Header.Add("Content-Encoding", ce)
-	res, err := h.send(req)
+	if r.IdempotencyKey != "" {
+		req.Header.Set(HeaderIdempotencyKey, r.IdempotencyKey)
+	}
+	var res *http.Response
+	if retry {
+		res, err = h.send(req, r.Timeout)
+	} else {
+		res, err = h.do(req, r.Timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	body, err := ioutil.ReadAll(res.Body)
-	return string(body[:]), nil
+	return newResponse(res, data), nil
 }
 
-func (h *httpClient) send(request *http.Request) (response *http.Response, err error) {
-	for i := 0; i <= h.retryCount; i++ {
-		response, err = h.client.Do(request)
-		if err != nil || response.StatusCode >= http.StatusInternalServerError {
-			time.Sleep(h.intervalTime)
-			continue
+func (h *httpClient) send(request *http.Request, timeout time.Duration) (*http.Response, error) {
+	ctx := request.Context()
+	p := h.retry
+	allowed := p.allowed(request)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := rewind(request); err != nil {
+				return nil, err
+			}
+		}
+		response, err := h.do(request, timeout)
+		if err == ErrCircuitOpen || ctx.Err() != nil {
+			return response, err
+		}
+		if !allowed || attempt >= p.MaxRetries || !p.retryable(request, response, err) {
+			return response, err
+		}
+		delay := p.backoff(attempt, response)
+		// 丢弃失败的响应，避免连接泄漏
+		discard(response)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
 		}
-		// 如果重试后成功传输，则将重试过程中的err置为nil
-		err = nil
-		break
 	}
-	return
+}
+
+// 发送一次请求，开启熔断时按Host记录结果
+func (h *httpClient) do(request *http.Request, timeout time.Duration) (*http.Response, error) {
+	client, t := h.client, h.client.Timeout
+	if timeout > 0 {
+		t = timeout
+	} else if h.live {
+		// http_timeout重新加载为0时不超时
+		t = time.Duration(atomic.LoadInt64(&liveTimeout))
+	}
+	if t != client.Timeout {
+		c := *h.client
+		c.Timeout = t
+		client = &c
+	}
+	if h.breakers == nil {
+		return client.Do(request)
+	}
+	b := h.breakers.get(request.URL.Host)
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	// 调用方取消或超时不代表服务端故障，不计入熔断统计
+	if request.Context().Err() != nil {
+		b.cancel()
+		return response, err
+	}
+	b.record(err == nil && response.StatusCode < http.StatusInternalServerError)
+	return response, err
 }
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"xianhetian.com/framework/algorithm/random"
+	"xianhetian.com/framework/logger"
+	"xianhetian.com/framework/token"
+)
+
+const HeaderRequestID = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// 将函数转换为http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// 中间件，包装下一层RoundTripper；每次发送（含重试）都会经过中间件
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// 按顺序组装中间件，第一个中间件位于最外层
+func chain(base http.RoundTripper, mws []Middleware) http.RoundTripper {
+	rt := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+// 记录请求与响应日志
+func Logging() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			logger.Debug("HTTP请求：%v %v", req.Method, req.URL.String())
+			res, err := next.RoundTrip(req)
+			elapsed := time.Since(start).String()
+			if err != nil {
+				logger.Error("HTTP请求失败：%v %v %v %v", req.Method, req.URL.String(), elapsed, err)
+				return res, err
+			}
+			logger.Info("HTTP响应：%v %v %v %v", req.Method, req.URL.String(), res.Status, elapsed)
+			return res, err
+		})
+	}
+}
+
+// 由source提供Token，以Authorization: Bearer请求头发送
+func BearerToken(source func() (string, error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			t, err := source()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+t)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// 使用token.NewToken签发Bearer Token，有效期为ttl（默认1小时），剩余不足十分之一时重新签发
+func TokenAuth(body token.Body, ttl time.Duration) Middleware {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	var (
+		mu      sync.Mutex
+		current string
+		expire  time.Time
+	)
+	return BearerToken(func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now()
+		if current != "" && expire.Sub(now) > ttl/10 {
+			return current, nil
+		}
+		b := body
+		b.Timestamp = now.Unix()
+		b.Timeout = now.Add(ttl).Unix()
+		t, err := token.NewToken(b)
+		if err != nil {
+			return "", err
+		}
+		current, expire = t, now.Add(ttl)
+		return current, nil
+	})
+}
+
+// 传递请求ID：优先使用context中的ID，其次是已有的请求头，都没有时生成新的ID
+func RequestID() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			id, ok := RequestIDFromContext(req.Context())
+			if !ok {
+				id = req.Header.Get(HeaderRequestID)
+			}
+			if id == "" {
+				id = NewRequestID()
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set(HeaderRequestID, id)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// 生成新的请求ID
+func NewRequestID() string {
+	return random.ValStr(16)
+}
+
+// 返回携带请求ID的context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// 获取context中的请求ID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
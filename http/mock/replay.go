@@ -0,0 +1,271 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	ModeReplay Mode = iota // 只回放，未匹配到记录时返回错误
+	ModeRecord             // 发送真实请求并记录，覆盖已有的记录文件
+	ModeAuto               // 记录文件存在时回放，否则记录
+)
+
+const redacted = "[REDACTED]"
+
+// 默认脱敏的请求头与响应头
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+type Mode int
+
+// 一次请求与响应的记录
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// 记录文件中的消息体，非UTF-8内容以BASE64保存
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	d, err := base64.StdEncoding.DecodeString(m["base64"])
+	*b = d
+	return err
+}
+
+// 判断请求是否与记录匹配
+type Matcher func(req *http.Request, body []byte, rec *RecordedRequest) bool
+
+// 比较请求方法
+func MatchMethod(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return req.Method == rec.Method
+}
+
+// 比较完整URL
+func MatchURL(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return req.URL.String() == rec.URL
+}
+
+// 比较请求体
+func MatchBody(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return bytes.Equal(body, rec.Body)
+}
+
+// 所有Matcher都匹配时才匹配
+func MatchAll(ms ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, rec *RecordedRequest) bool {
+		for _, m := range ms {
+			if !m(req, body, rec) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+/*
+记录与回放请求的http.RoundTripper，可作为http.Options.Transport使用
+回放时每条记录只使用一次，按记录顺序匹配第一条未使用的记录
+*/
+type Recorder struct {
+	Path          string            // 记录文件路径（JSON）
+	Mode          Mode              // 运行模式
+	Matcher       Matcher           // 匹配规则，默认比较方法与URL
+	RedactHeaders []string          // 保存前脱敏的请求头与响应头，默认DefaultRedactHeaders
+	Next          http.RoundTripper // 记录时使用的真实Transport，默认http.DefaultTransport
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// 返回一个Recorder，回放模式下读取记录文件
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	if mode == ModeAuto {
+		if _, err := os.Stat(path); err == nil {
+			r.Mode = ModeReplay
+		} else {
+			r.Mode = ModeRecord
+		}
+	}
+	if r.Mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("mock: invalid fixture %s: %v", path, err)
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.Mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	match := r.Matcher
+	if match == nil {
+		match = MatchAll(MatchMethod, MatchURL)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.interactions {
+		if r.used[i] || !match(req, body, &it.Request) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", it.Response.StatusCode, http.StatusText(it.Response.StatusCode)),
+			StatusCode:    it.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        it.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(it.Response.Body)),
+			ContentLength: int64(len(it.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("mock: no recorded interaction for %s %s", req.Method, req.URL.String())
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(data))
+	it := &Interaction{
+		Request:  RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: r.redact(req.Header), Body: body},
+		Response: RecordedResponse{StatusCode: res.StatusCode, Header: r.redact(res.Header), Body: data},
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, it)
+	r.mu.Unlock()
+	return res, nil
+}
+
+// 将记录写入记录文件，记录模式下测试结束时调用
+func (r *Recorder) Save() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.Path, data, 0644)
+}
+
+// 回放模式下返回未被使用的记录数
+func (r *Recorder) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, u := range r.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Recorder) redact(h http.Header) http.Header {
+	names := r.RedactHeaders
+	if names == nil {
+		names = DefaultRedactHeaders
+	}
+	h = h.Clone()
+	for _, name := range names {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+// 读取请求体并放回，使请求仍可发送
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	var rc io.ReadCloser = req.Body
+	if req.GetBody != nil {
+		var err error
+		if rc, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	if req.GetBody == nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	return data, nil
+}
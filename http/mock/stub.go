@@ -0,0 +1,153 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// 断言失败时使用的测试接口，*testing.T满足该接口
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// 进程内的桩服务，按方法与路径返回预设响应并记录收到的请求
+type Stub struct {
+	*httptest.Server
+	mu     sync.Mutex
+	routes []*StubRoute
+	calls  []Call
+}
+
+// 桩服务收到的请求
+type Call struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// 预设的响应
+type StubRoute struct {
+	method string
+	path   string
+	status int
+	header http.Header
+	body   []byte
+	handle http.HandlerFunc
+}
+
+// 启动一个桩服务，测试结束后调用Close
+func NewStub() *Stub {
+	s := &Stub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// 注册预设响应，method为空时匹配所有方法；后注册的优先
+func (s *Stub) On(method, path string) *StubRoute {
+	r := &StubRoute{method: method, path: path, status: http.StatusOK, header: make(http.Header)}
+	s.mu.Lock()
+	s.routes = append(s.routes, r)
+	s.mu.Unlock()
+	return r
+}
+
+// 设置响应状态码与响应体，body为[]byte或string时原样返回，其它类型按JSON编码
+func (r *StubRoute) Reply(status int, body interface{}) *StubRoute {
+	r.status = status
+	switch b := body.(type) {
+	case nil:
+		r.body = nil
+	case []byte:
+		r.body = b
+	case string:
+		r.body = []byte(b)
+	default:
+		r.body, _ = json.Marshal(b)
+		if r.header.Get("Content-Type") == "" {
+			r.header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+	}
+	return r
+}
+
+// 设置响应头
+func (r *StubRoute) Header(key, value string) *StubRoute {
+	r.header.Set(key, value)
+	return r
+}
+
+// 使用自定义处理函数生成响应
+func (r *StubRoute) HandleFunc(f http.HandlerFunc) *StubRoute {
+	r.handle = f
+	return r
+}
+
+// 返回收到的所有请求
+func (s *Stub) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// 返回匹配方法与路径的请求，method为空时匹配所有方法
+func (s *Stub) CallsTo(method, path string) []Call {
+	var calls []Call
+	for _, c := range s.Calls() {
+		if (method == "" || c.Method == method) && c.Path == path {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// 断言匹配方法与路径的请求次数
+func (s *Stub) AssertCalled(t T, method, path string, times int) {
+	t.Helper()
+	if n := len(s.CallsTo(method, path)); n != times {
+		t.Errorf("mock: expected %d calls to %s %s, got %d", times, method, path, n)
+	}
+}
+
+// 清空已记录的请求
+func (s *Stub) Reset() {
+	s.mu.Lock()
+	s.calls = nil
+	s.mu.Unlock()
+}
+
+func (s *Stub) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: req.Method, Path: req.URL.Path, Query: req.URL.Query(), Header: req.Header.Clone(), Body: body})
+	var route *StubRoute
+	for i := len(s.routes) - 1; i >= 0; i-- {
+		r := s.routes[i]
+		if (r.method == "" || r.method == req.Method) && r.path == req.URL.Path {
+			route = r
+			break
+		}
+	}
+	s.mu.Unlock()
+	if route == nil {
+		http.NotFound(w, req)
+		return
+	}
+	if route.handle != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		route.handle(w, req)
+		return
+	}
+	for k, v := range route.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(route.status)
+	w.Write(route.body)
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"xianhetian.com/framework/cache"
+	"xianhetian.com/framework/logger"
+)
+
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuth2客户端配置
+type OAuth2Config struct {
+	TokenURL     string   // 获取Token的地址
+	ClientID     string   // 客户端ID
+	ClientSecret string   // 客户端密钥
+	Scopes       []string // 申请的权限范围
+	GrantType    string   // 授权方式：client_credentials（默认）或refresh_token
+	RefreshToken string   // GrantType为refresh_token时使用的刷新令牌
+	AuthInBody   bool     // 是否在请求体中发送client_id与client_secret，默认使用Basic认证
+
+	CacheKey    string        // 不为空时将Token保存到cache包的Redis中，多个副本共享
+	ExpiryDelta time.Duration // 提前刷新的时间，默认30秒
+	Timeout     time.Duration // 获取Token的超时时间，默认10秒
+}
+
+// OAuth2访问令牌
+type OAuth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+type oauth2Source struct {
+	cfg     OAuth2Config
+	client  *http.Client
+	lock    chan struct{} // 容量为1，保证同时只有一个获取Token的请求，等待时可被ctx取消
+	token   *OAuth2Token
+	refresh string
+}
+
+/*
+返回OAuth2中间件，使用client_credentials或refresh_token方式自动获取Token并缓存到过期前，
+收到401时重新获取Token并重试一次
+*/
+func OAuth2(cfg OAuth2Config) Middleware {
+	if cfg.GrantType == "" {
+		cfg.GrantType = GrantClientCredentials
+	}
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	s := &oauth2Source{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}, lock: make(chan struct{}, 1), refresh: cfg.RefreshToken}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			t, err := s.get(req.Context(), "")
+			if err != nil {
+				return nil, err
+			}
+			res, err := next.RoundTrip(authorize(req, t))
+			if err != nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+			// 请求体无法重新读取时不重试
+			if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+				return res, nil
+			}
+			fresh, err := s.get(req.Context(), t.AccessToken)
+			if err != nil || fresh.AccessToken == t.AccessToken {
+				return res, nil
+			}
+			retry := authorize(req, fresh)
+			if err = rewind(retry); err != nil {
+				return res, nil
+			}
+			discard(res)
+			return next.RoundTrip(retry)
+		})
+	}
+}
+
+func authorize(req *http.Request, t *OAuth2Token) *http.Request {
+	req = req.Clone(req.Context())
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	req.Header.Set("Authorization", typ+" "+t.AccessToken)
+	return req
+}
+
+// 返回有效的Token，stale不为空时表示该Token已被服务端拒绝，不再使用；ctx取消时停止等待与获取
+func (s *oauth2Source) get(ctx context.Context, stale string) (*OAuth2Token, error) {
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-s.lock }()
+	if stale != "" && s.token != nil && s.token.AccessToken == stale {
+		s.token = nil
+	}
+	if s.valid(s.token) {
+		return s.token, nil
+	}
+	if t := s.cacheGet(); s.valid(t) && t.AccessToken != stale {
+		s.token = t
+		return t, nil
+	}
+	t, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = t
+	s.cacheSet(t)
+	return t, nil
+}
+
+func (s *oauth2Source) valid(t *OAuth2Token) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(s.cfg.ExpiryDelta).Before(t.Expiry))
+}
+
+func (s *oauth2Source) fetch(ctx context.Context) (*OAuth2Token, error) {
+	form := url.Values{"grant_type": {s.cfg.GrantType}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.GrantType == GrantRefreshToken {
+		if s.refresh == "" {
+			return nil, errors.New("http: oauth2 refresh token is empty")
+		}
+		form.Set("refresh_token", s.refresh)
+	}
+	if s.cfg.AuthInBody {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ContentTypeForm)
+	req.Header.Set("Accept", "application/json")
+	if !s.cfg.AuthInBody {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http: oauth2 token request failed: %s %s", res.Status, body)
+	}
+	t := new(OAuth2Token)
+	if err = json.Unmarshal(body, t); err != nil {
+		return nil, err
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("http: oauth2 server returned empty access token")
+	}
+	if t.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	if t.RefreshToken != "" {
+		s.refresh = t.RefreshToken
+	}
+	return t, nil
+}
+
+// 以下缓存操作失败时只记录日志，退化为仅使用内存中的Token；直接使用cache.Do，避免cache包在日志中输出Token
+func (s *oauth2Source) cacheGet() (t *OAuth2Token) {
+	if s.cfg.CacheKey == "" {
+		return nil
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("OAuth2 Token缓存读取失败：%v", fmt.Sprint(err))
+			t = nil
+		}
+	}()
+	b, err := redis.Bytes(cache.Do("GET", s.cfg.CacheKey))
+	if err != nil {
+		if err != redis.ErrNil {
+			logger.Error("OAuth2 Token缓存读取失败：%v", err)
+		}
+		return nil
+	}
+	t = new(OAuth2Token)
+	if err = json.Unmarshal(b, t); err != nil {
+		logger.Error("OAuth2 Token缓存读取失败：%v", err)
+		return nil
+	}
+	return t
+}
+
+func (s *oauth2Source) cacheSet(t *OAuth2Token) {
+	if s.cfg.CacheKey == "" {
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("OAuth2 Token缓存写入失败：%v", fmt.Sprint(err))
+		}
+	}()
+	ttl := 3600
+	if !t.Expiry.IsZero() {
+		ttl = int(time.Until(t.Expiry) / time.Second)
+	}
+	if ttl <= 0 {
+		return
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		logger.Error("OAuth2 Token缓存写入失败：%v", err)
+		return
+	}
+	if _, err = cache.Do("SET", s.cfg.CacheKey, b, "EX", ttl); err != nil {
+		logger.Error("OAuth2 Token缓存写入失败：%v", err)
+	}
+}
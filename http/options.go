@@ -0,0 +1,172 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+	cf "xianhetian.com/framework/config"
+)
+
+// HTTPClient的配置
+type Options struct {
+	Timeout             time.Duration // 单次请求的超时时间，0为不超时
+	LiveTimeout         bool          // 是否忽略Timeout，使用配置项http_timeout的当前值，随配置重新加载变化
+	DialTimeout         time.Duration // 建立TCP连接的超时时间
+	KeepAlive           time.Duration // TCP keep-alive探测间隔
+	TLSHandshakeTimeout time.Duration // TLS握手超时时间
+	DisableKeepAlives   bool          // 是否关闭连接复用，每次请求后关闭连接
+	MaxIdleConns        int           // 所有Host的最大空闲连接数，0为不限制
+	MaxIdleConnsPerHost int           // 每个Host的最大空闲连接数
+	MaxConnsPerHost     int           // 每个Host的最大连接数，0为不限制
+	IdleConnTimeout     time.Duration // 空闲连接的过期时间
+
+	CAFile             string // CA证书文件（PEM），为空时使用系统证书
+	CertFile           string // 客户端证书文件（PEM），用于双向认证
+	KeyFile            string // 客户端私钥文件（PEM）
+	MinTLSVersion      string // 最低TLS版本：1.0、1.1、1.2、1.3
+	InsecureSkipVerify bool   // 是否跳过服务端证书校验，仅用于测试
+
+	Proxy string // 代理地址，为空时使用HTTP_PROXY等环境变量
+	HTTP2 bool   // 是否尝试使用HTTP/2
+
+	ContentEncoding   string // 请求体压缩方式：identity、gzip、deflate、br
+	CompressThreshold int    // 请求体达到该字节数才压缩
+
+	Transport   http.RoundTripper // 自定义底层Transport，设置后忽略以上连接配置
+	Retry       *RetryPolicy      // 重试策略，为nil时不重试
+	Breaker     *BreakerConfig    // 熔断器配置，为nil时不熔断
+	Middlewares []Middleware      // 中间件
+	OAuth2      *OAuth2Config     // OAuth2配置，不为nil时自动获取并携带Token
+}
+
+// 返回根据framework.conf配置生成的默认配置
+func DefaultOptions() *Options {
+	o := &Options{
+		Timeout:             time.Duration(ht) * time.Millisecond,
+		DialTimeout:         time.Duration(cf.Config.DefaultInt("http_dial_timeout", "5000")) * time.Millisecond,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: time.Duration(cf.Config.DefaultInt("http_tls_handshake_timeout", "10000")) * time.Millisecond,
+		DisableKeepAlives:   conn == "close",
+		MaxIdleConns:        cf.Config.DefaultInt("http_max_idle_conns", "100"),
+		MaxIdleConnsPerHost: cf.Config.DefaultInt("http_max_idle_conns_per_host", "10"),
+		MaxConnsPerHost:     cf.Config.DefaultInt("http_max_conns_per_host", "0"),
+		IdleConnTimeout:     time.Duration(ka) * time.Second,
+		CAFile:              cf.Config.DefaultString("http_tls_ca_file", ""),
+		CertFile:            cf.Config.DefaultString("http_tls_cert_file", ""),
+		KeyFile:             cf.Config.DefaultString("http_tls_key_file", ""),
+		MinTLSVersion:       cf.Config.DefaultString("http_tls_min_version", "1.2"),
+		InsecureSkipVerify:  cf.Config.DefaultBool("http_tls_insecure", "false"),
+		Proxy:               cf.Config.DefaultString("http_proxy", ""),
+		HTTP2:               cf.Config.DefaultBool("http_http2", "true"),
+		ContentEncoding:     ce,
+		CompressThreshold:   hct,
+		Retry:               DefaultRetryPolicy(),
+	}
+	if hb {
+		o.Breaker = DefaultBreakerConfig()
+	}
+	return o
+}
+
+// 根据配置生成底层Transport，并加上压缩与解压
+func (o *Options) transport() (http.RoundTripper, error) {
+	if !validEncoding(o.ContentEncoding) {
+		return nil, fmt.Errorf("http: unsupported content encoding %s", o.ContentEncoding)
+	}
+	rt, err := o.baseTransport()
+	if err != nil {
+		return nil, err
+	}
+	return &encodingTransport{next: rt, encoding: o.ContentEncoding, threshold: int64(o.CompressThreshold)}, nil
+}
+
+func validEncoding(e string) bool {
+	switch e {
+	case "", EncodingIdentity, EncodingGzip, EncodingDeflate, EncodingBrotli:
+		return true
+	}
+	return false
+}
+
+func (o *Options) baseTransport() (http.RoundTripper, error) {
+	if o.Transport != nil {
+		return o.Transport, nil
+	}
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy := http.ProxyFromEnvironment
+	if o.Proxy != "" {
+		u, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("http: invalid proxy %s: %v", o.Proxy, err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	dialer := &net.Dialer{Timeout: o.DialTimeout, KeepAlive: o.KeepAlive}
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: o.TLSHandshakeTimeout,
+		DisableKeepAlives:   o.DisableKeepAlives,
+		MaxIdleConns:        o.MaxIdleConns,
+		MaxIdleConnsPerHost: o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     o.MaxConnsPerHost,
+		IdleConnTimeout:     o.IdleConnTimeout,
+		ForceAttemptHTTP2:   o.HTTP2,
+	}, nil
+}
+
+func (o *Options) tlsConfig() (*tls.Config, error) {
+	version, err := tlsVersion(o.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Config{MinVersion: version, InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("http: no certificates found in %s", o.CAFile)
+		}
+		c.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, errors.New("http: both cert file and key file are required")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("http: unsupported tls version %s", v)
+}
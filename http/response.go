@@ -0,0 +1,61 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// HTTP响应
+type Response struct {
+	StatusCode int         // 状态码
+	Status     string      // 状态描述，如"200 OK"
+	Header     http.Header // 响应头
+	Body       []byte      // 响应体
+}
+
+func newResponse(res *http.Response, body []byte) *Response {
+	return &Response{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Header:     res.Header,
+		Body:       body,
+	}
+}
+
+// 状态码是否为2xx
+func (r *Response) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// 返回响应体字符串
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// 将响应体按JSON解析到v
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// 状态码为2xx时将响应体按JSON解析到v，否则返回包含状态码的错误
+func (r *Response) Decode(v interface{}) error {
+	if !r.OK() {
+		return &StatusError{StatusCode: r.StatusCode, Status: r.Status, Body: r.Body}
+	}
+	if v == nil || len(r.Body) == 0 {
+		return nil
+	}
+	return r.JSON(v)
+}
+
+// 非2xx响应的错误
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http: unexpected status %s", e.Status)
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 请求头中的幂等键，设置后非幂等方法也可重试
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// 重试策略
+type RetryPolicy struct {
+	MaxRetries int           // 最大重试次数，0为不重试
+	BaseDelay  time.Duration // 首次重试的间隔时间，之后每次翻倍
+	MaxDelay   time.Duration // 重试间隔的上限，0为不限制
+	Jitter     float64       // 随机抖动比例，取值0~1，如0.2表示间隔在±20%范围内浮动
+	// 自定义重试条件，为nil时网络错误、429及502/503/504重试；
+	// 只对幂等方法或带有幂等键的请求生效
+	RetryOn func(req *http.Request, res *http.Response, err error) bool
+}
+
+// 返回根据framework.conf配置生成的默认重试策略
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries: htc,
+		BaseDelay:  time.Duration(hit) * time.Millisecond,
+		MaxDelay:   time.Duration(hmd) * time.Millisecond,
+		Jitter:     0.2,
+	}
+}
+
+// 请求是否允许重试：方法幂等或带有幂等键，且请求体可以重新读取
+func (p *RetryPolicy) allowed(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get(HeaderIdempotencyKey) != ""
+}
+
+// 本次结果是否需要重试
+func (p *RetryPolicy) retryable(req *http.Request, res *http.Response, err error) bool {
+	if p.RetryOn != nil {
+		return p.RetryOn(req, res, err)
+	}
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// 第attempt次重试前的等待时间，响应带有Retry-After时优先使用
+func (p *RetryPolicy) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if d, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+			if p.MaxDelay > 0 && d > p.MaxDelay {
+				d = p.MaxDelay
+			}
+			return d
+		}
+	}
+	d := p.BaseDelay << uint(attempt)
+	if d < 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		delta := p.Jitter * float64(d)
+		d += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	return d
+}
+
+// 解析Retry-After，支持秒数与HTTP时间两种格式
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// 重新生成请求体，以便同一个请求再次发送
+func rewind(req *http.Request) error {
+	if req.Body == nil || req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// 读完并关闭响应体，使连接可以复用
+func discard(res *http.Response) {
+	if res == nil || res.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 请求体的最大字节数
+var MaxBodySize int64 = 10 << 20
+
+// 错误响应体
+type ErrorBody struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// 字段校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// 校验失败的错误，包含所有未通过的字段
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Message
+	}
+	return "server: validation failed: " + strings.Join(msgs, "; ")
+}
+
+// 将JSON响应写入w
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// 写入JSON格式的错误响应
+func Error(w http.ResponseWriter, status int, message string) {
+	JSON(w, status, &ErrorBody{Code: status, Message: message})
+}
+
+/*
+解析JSON请求体到v并按validate标签校验
+支持的规则：required、min=n、max=n，数字比较数值，字符串、切片与map比较长度
+如：Name string `json:"name" validate:"required,max=32"`
+*/
+func Bind(r *http.Request, v interface{}) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			return fmt.Errorf("server: unsupported content type %s", ct)
+		}
+	}
+	dec := json.NewDecoder(io.LimitReader(r.Body, MaxBodySize))
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return errors.New("server: empty request body")
+		}
+		return err
+	}
+	return Validate(v)
+}
+
+// 解析并校验请求体，失败时写入400错误响应并返回false
+func BindOrError(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := Bind(r, v)
+	if err == nil {
+		return true
+	}
+	if ve, ok := err.(*ValidationError); ok {
+		JSON(w, http.StatusBadRequest, &ErrorBody{Code: http.StatusBadRequest, Message: "validation failed", Fields: ve.Fields})
+		return false
+	}
+	Error(w, http.StatusBadRequest, err.Error())
+	return false
+}
+
+// 按validate标签校验结构体，v可以是结构体或结构体指针
+func Validate(v interface{}) error {
+	var fields []FieldError
+	validate(reflect.ValueOf(v), "", &fields)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func validate(v reflect.Value, prefix string, fields *[]FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := fieldName(f, prefix)
+		fv := v.Field(i)
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			if rule = strings.TrimSpace(rule); rule == "" {
+				continue
+			}
+			if msg := check(fv, rule); msg != "" {
+				*fields = append(*fields, FieldError{Field: name, Rule: rule, Message: name + " " + msg})
+			}
+		}
+		validate(fv, name+".", fields)
+	}
+}
+
+func fieldName(f reflect.StructField, prefix string) string {
+	name := f.Name
+	if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+		name = tag
+	}
+	return prefix + name
+}
+
+// 校验单条规则，通过时返回空字符串
+func check(v reflect.Value, rule string) string {
+	key, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		key, arg = rule[:i], rule[i+1:]
+	}
+	switch key {
+	case "required":
+		if isZero(v) {
+			return "is required"
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "has invalid rule " + rule
+		}
+		n, isLen, ok := measure(v)
+		if !ok {
+			return ""
+		}
+		if (key == "min" && n < limit) || (key == "max" && n > limit) {
+			if isLen {
+				return fmt.Sprintf("length must be %s %s", bound(key), arg)
+			}
+			return fmt.Sprintf("must be %s %s", bound(key), arg)
+		}
+	default:
+		return "has unknown rule " + rule
+	}
+	return ""
+}
+
+func bound(key string) string {
+	if key == "min" {
+		return "at least"
+	}
+	return "at most"
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// 返回用于min、max比较的值，isLen表示比较的是长度
+func measure(v reflect.Value) (n float64, isLen bool, ok bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, false, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), true, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	return 0, false, false
+}
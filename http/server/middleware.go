@@ -0,0 +1,308 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+	fhttp "xianhetian.com/framework/http"
+	"xianhetian.com/framework/logger"
+)
+
+// 中间件，包装下一层http.Handler
+type Middleware func(next http.Handler) http.Handler
+
+// 按顺序组装中间件，第一个中间件位于最外层
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+/*
+捕获处理过程中的panic，记录日志并返回500；
+响应已开始写入时无法再返回500，中断连接使客户端得知响应不完整
+*/
+func Recovery() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
+					logger.Error("HTTP处理异常：%v %v %v %v", r.Method, r.URL.Path, fmt.Sprint(err), string(debug.Stack()))
+					if sw.code != 0 {
+						panic(http.ErrAbortHandler)
+					}
+					Error(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// 记录请求日志：方法、路径、状态码、响应字节数与耗时
+func Logging() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			id, _ := fhttp.RequestIDFromContext(r.Context())
+			logger.Info("HTTP请求：%v %v %v %v %v %v", r.Method, r.URL.RequestURI(), strconv.Itoa(sw.status()),
+				strconv.Itoa(sw.size), time.Since(start).String(), id)
+		})
+	}
+}
+
+// 读取或生成请求ID，写入响应头并保存到context，供http客户端的RequestID中间件向下游传递
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(fhttp.HeaderRequestID)
+			if id == "" {
+				id = fhttp.NewRequestID()
+			}
+			w.Header().Set(fhttp.HeaderRequestID, id)
+			next.ServeHTTP(w, r.WithContext(fhttp.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// 跨域配置
+type CORSConfig struct {
+	AllowOrigins     []string // 允许的来源，包含"*"时允许所有来源
+	AllowMethods     []string // 允许的方法，默认GET、POST、PUT、PATCH、DELETE、HEAD
+	AllowHeaders     []string // 允许的请求头，为空时回显预检请求的Access-Control-Request-Headers
+	ExposeHeaders    []string // 允许浏览器读取的响应头
+	AllowCredentials bool     // 是否允许携带Cookie
+	MaxAge           time.Duration
+}
+
+// 处理跨域请求，预检请求直接返回204
+func CORS(c CORSConfig) Middleware {
+	methods := c.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	}
+	allowAll := false
+	origins := make(map[string]bool)
+	for _, o := range c.AllowOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+		origins[o] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !(allowAll || origins[origin]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if allowAll && !c.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(c.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+			}
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(c.AllowHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+			} else if rh := r.Header.Get("Access-Control-Request-Headers"); rh != "" {
+				h.Set("Access-Control-Allow-Headers", rh)
+			}
+			if c.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// 客户端支持gzip时压缩响应体
+func Gzip() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipWriter{ResponseWriter: w}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+/*
+按Content-Encoding解压请求体，支持gzip、deflate（zlib格式）、br，其它编码返回415；
+应放在读取请求体的中间件（如signature.Verify）之前，使其看到的是解压后的请求体
+*/
+func Decompress() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if enc == "" || enc == fhttp.EncodingIdentity || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var (
+				rd  io.Reader
+				err error
+			)
+			switch enc {
+			case fhttp.EncodingGzip:
+				rd, err = gzip.NewReader(r.Body)
+			case fhttp.EncodingDeflate:
+				rd, err = zlib.NewReader(r.Body)
+			case fhttp.EncodingBrotli:
+				rd = brotli.NewReader(r.Body)
+			default:
+				Error(w, http.StatusUnsupportedMediaType, "unsupported content encoding "+enc)
+				return
+			}
+			if err != nil {
+				Error(w, http.StatusBadRequest, "invalid "+enc+" request body")
+				return
+			}
+			r = r.Clone(r.Context())
+			r.Body = &decompressBody{Reader: rd, body: r.Body}
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type decompressBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (d *decompressBody) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return d.body.Close()
+}
+
+// 记录状态码与响应字节数的ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+	size int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("server: response writer does not support hijacking")
+}
+
+// 延迟到第一次写入时才决定是否压缩，已设置Content-Encoding的响应不重复压缩
+type gzipWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	w.decide(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipWriter) decide(code int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if code == http.StatusNoContent || code == http.StatusNotModified || h.Get("Content-Encoding") != "" {
+		return
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
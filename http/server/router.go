@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type paramsKey struct{}
+
+// 路由，支持:name路径参数与*name通配参数，实现http.Handler
+type Router struct {
+	root        *node
+	middlewares []Middleware
+	handler     http.Handler // 组装中间件后的入口
+	NotFound    http.Handler // 未匹配到路由时的处理，默认返回404
+}
+
+type node struct {
+	children map[string]*node
+	param    *node  // :name子节点
+	wildcard *node  // *name子节点，匹配剩余全部路径
+	name     string // 参数名
+	handlers map[string]http.Handler
+}
+
+// 返回一个新的路由
+func NewRouter() *Router {
+	r := &Router{root: newNode()}
+	r.handler = http.HandlerFunc(r.serve)
+	return r
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node), handlers: make(map[string]http.Handler)}
+}
+
+// 追加中间件，先追加的中间件位于外层
+func (r *Router) Use(mws ...Middleware) {
+	r.middlewares = append(r.middlewares, mws...)
+	r.handler = Chain(http.HandlerFunc(r.serve), r.middlewares...)
+}
+
+// 注册路由，pattern如/users/:id、/static/*path
+func (r *Router) Handle(method, pattern string, h http.Handler) {
+	n := r.root
+	for _, seg := range split(pattern) {
+		if seg == "" || seg == ":" || seg == "*" {
+			panic("server: empty path segment or parameter name in " + pattern)
+		}
+		switch seg[0] {
+		case ':':
+			if n.param == nil {
+				n.param = newNode()
+				n.param.name = seg[1:]
+			} else if n.param.name != seg[1:] {
+				panic("server: conflicting param name " + seg + " in " + pattern)
+			}
+			n = n.param
+		case '*':
+			if n.wildcard == nil {
+				n.wildcard = newNode()
+				n.wildcard.name = seg[1:]
+			}
+			n = n.wildcard
+		default:
+			child, ok := n.children[seg]
+			if !ok {
+				child = newNode()
+				n.children[seg] = child
+			}
+			n = child
+		}
+	}
+	if _, ok := n.handlers[method]; ok {
+		panic("server: duplicate route " + method + " " + pattern)
+	}
+	n.handlers[method] = h
+}
+
+// 注册处理函数
+func (r *Router) HandleFunc(method, pattern string, f http.HandlerFunc) {
+	r.Handle(method, pattern, f)
+}
+
+func (r *Router) GET(pattern string, f http.HandlerFunc) {
+	r.Handle(http.MethodGet, pattern, f)
+}
+
+func (r *Router) POST(pattern string, f http.HandlerFunc) {
+	r.Handle(http.MethodPost, pattern, f)
+}
+
+func (r *Router) PUT(pattern string, f http.HandlerFunc) {
+	r.Handle(http.MethodPut, pattern, f)
+}
+
+func (r *Router) PATCH(pattern string, f http.HandlerFunc) {
+	r.Handle(http.MethodPatch, pattern, f)
+}
+
+func (r *Router) DELETE(pattern string, f http.HandlerFunc) {
+	r.Handle(http.MethodDelete, pattern, f)
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *Router) serve(w http.ResponseWriter, req *http.Request) {
+	params := make(map[string]string)
+	allow := make(map[string]bool)
+	n := r.root.match(split(req.URL.Path), req.Method, params, allow)
+	if n == nil && len(allow) == 0 {
+		r.notFound(w, req)
+		return
+	}
+	if n == nil {
+		methods := make([]string, 0, len(allow))
+		for m := range allow {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		Error(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	h, ok := n.handlers[req.Method]
+	if !ok {
+		h = n.handlers[http.MethodGet]
+	}
+	if len(params) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), paramsKey{}, params))
+	}
+	h.ServeHTTP(w, req)
+}
+
+func (r *Router) notFound(w http.ResponseWriter, req *http.Request) {
+	if r.NotFound != nil {
+		r.NotFound.ServeHTTP(w, req)
+		return
+	}
+	Error(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+}
+
+/*
+匹配路径，静态节点优先于参数节点，参数节点优先于通配节点；
+只返回支持method的节点，路径匹配但不支持method的节点继续尝试其它分支，其方法记录在allow中
+*/
+func (n *node) match(segs []string, method string, params map[string]string, allow map[string]bool) *node {
+	if len(segs) == 0 {
+		if n.accept(method, allow) {
+			return n
+		}
+		if n.wildcard != nil && n.wildcard.accept(method, allow) {
+			params[n.wildcard.name] = ""
+			return n.wildcard
+		}
+		return nil
+	}
+	if child, ok := n.children[segs[0]]; ok {
+		if m := child.match(segs[1:], method, params, allow); m != nil {
+			return m
+		}
+	}
+	if n.param != nil {
+		if m := n.param.match(segs[1:], method, params, allow); m != nil {
+			params[n.param.name] = segs[0]
+			return m
+		}
+	}
+	if n.wildcard != nil && n.wildcard.accept(method, allow) {
+		params[n.wildcard.name] = strings.Join(segs, "/")
+		return n.wildcard
+	}
+	return nil
+}
+
+// 节点是否有method的处理函数，HEAD可由GET处理；不支持时将节点的方法加入allow
+func (n *node) accept(method string, allow map[string]bool) bool {
+	if _, ok := n.handlers[method]; ok {
+		return true
+	}
+	if _, ok := n.handlers[http.MethodGet]; ok && method == http.MethodHead {
+		return true
+	}
+	for m := range n.handlers {
+		allow[m] = true
+	}
+	return false
+}
+
+// 获取路径参数
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
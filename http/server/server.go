@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	cf "xianhetian.com/framework/config"
+	"xianhetian.com/framework/logger"
+)
+
+var (
+	addr            = cf.Config.DefaultString("server_addr", ":8080")          // 监听地址
+	readTimeout     = cf.Config.DefaultInt("server_read_timeout", "30000")     // 读取请求超时时间
+	writeTimeout    = cf.Config.DefaultInt("server_write_timeout", "30000")    // 写入响应超时时间
+	idleTimeout     = cf.Config.DefaultInt("server_idle_timeout", "60000")     // 空闲连接超时时间
+	shutdownTimeout = cf.Config.DefaultInt("server_shutdown_timeout", "15000") // 优雅关闭的最长等待时间
+)
+
+// 支持优雅关闭的HTTP服务
+type Server struct {
+	*http.Server
+	ShutdownTimeout time.Duration // 收到退出信号后等待进行中请求完成的最长时间
+}
+
+// 返回一个HTTP服务，addr为空时使用server_addr配置
+func New(address string, handler http.Handler) *Server {
+	if address == "" {
+		address = addr
+	}
+	return &Server{
+		Server: &http.Server{
+			Addr:         address,
+			Handler:      handler,
+			ReadTimeout:  time.Duration(readTimeout) * time.Millisecond,
+			WriteTimeout: time.Duration(writeTimeout) * time.Millisecond,
+			IdleTimeout:  time.Duration(idleTimeout) * time.Millisecond,
+		},
+		ShutdownTimeout: time.Duration(shutdownTimeout) * time.Millisecond,
+	}
+}
+
+// 启动服务并阻塞，收到SIGINT或SIGTERM后停止接收新连接，等待进行中的请求完成后返回
+func (s *Server) Run() error {
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("HTTP服务启动：%v", s.Addr)
+		errCh <- s.ListenAndServe()
+	}()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	select {
+	case err := <-errCh:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case sig := <-sigCh:
+		logger.Info("HTTP服务收到信号%v，开始关闭", sig.String())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		logger.Error("HTTP服务关闭失败：%v", err)
+		return err
+	}
+	logger.Info("HTTP服务已关闭")
+	return nil
+}
@@ -0,0 +1,228 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"xianhetian.com/framework/algorithm/random"
+	"xianhetian.com/framework/cache"
+	fhttp "xianhetian.com/framework/http"
+	"xianhetian.com/framework/logger"
+)
+
+var (
+	ErrMissingSignature = errors.New("signature: missing signature headers")
+	ErrUnknownKey       = errors.New("signature: unknown key id")
+	ErrClockSkew        = errors.New("signature: timestamp outside allowed clock skew")
+	ErrReplay           = errors.New("signature: nonce already used")
+	ErrBodyHash         = errors.New("signature: body hash mismatch")
+)
+
+// 最大可校验的请求体字节数
+var MaxBodySize int64 = 10 << 20
+
+/*
+返回为请求签名的http客户端中间件
+签名前按Signer生成待签名字符串，并写入时间戳、随机数、请求体哈希、密钥标识与签名请求头；
+请求体需可重复读取（由http客户端构造的请求均满足）；
+签名基于压缩前的请求体，启用请求压缩时服务端须在Verify之外层使用server.Decompress解压
+*/
+func Middleware(keyID string, s Signer) fhttp.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return fhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := requestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			nonce := random.ValStr(16)
+			hash := BodyHash(body)
+			sign, err := s.Sign(Canonical(req.Method, req.URL.EscapedPath(), req.URL.Query(), hash, ts, nonce))
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set(HeaderTimestamp, ts)
+			req.Header.Set(HeaderNonce, nonce)
+			req.Header.Set(HeaderContentHash, hash)
+			req.Header.Set(HeaderAlgorithm, s.Algorithm())
+			req.Header.Set(HeaderKeyID, keyID)
+			req.Header.Set(HeaderSignature, sign)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// 防重放的随机数存储
+type NonceStore interface {
+	// 记录随机数，ttl内已记录过时返回false
+	Use(nonce string, ttl time.Duration) (bool, error)
+}
+
+// 基于cache包Redis的随机数存储，多个副本共享
+type CacheNonceStore struct {
+	Prefix string // 键前缀，默认"signature:nonce:"
+}
+
+func (c *CacheNonceStore) Use(nonce string, ttl time.Duration) (ok bool, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("signature: nonce store unavailable: %v", e)
+		}
+	}()
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = "signature:nonce:"
+	}
+	seconds := int(ttl / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+	r, err := cache.Do("SET", prefix+nonce, "1", "EX", seconds, "NX")
+	if err != nil {
+		return false, err
+	}
+	return r != nil, nil
+}
+
+// 进程内的随机数存储，用于单实例或测试
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func (m *MemoryNonceStore) Use(nonce string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.nonces == nil {
+		m.nonces = make(map[string]time.Time)
+	}
+	for k, exp := range m.nonces {
+		if now.After(exp) {
+			delete(m.nonces, k)
+		}
+	}
+	if _, ok := m.nonces[nonce]; ok {
+		return false, nil
+	}
+	m.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// 服务端验签配置
+type VerifyConfig struct {
+	Keys    func(keyID string) (Verifier, error)                    // 根据密钥标识返回验签器
+	MaxSkew time.Duration                                           // 允许的时钟偏差，默认5分钟
+	Nonces  NonceStore                                              // 随机数存储，默认CacheNonceStore
+	OnError func(w http.ResponseWriter, r *http.Request, err error) // 验签失败时的响应，默认记录日志并返回401
+}
+
+/*
+返回验签的net/http中间件
+校验时间戳在允许的时钟偏差内、请求体哈希一致、签名正确，最后记录随机数防止重放；
+请求体须为解压后的数据，客户端启用请求压缩时按server.Chain(h, server.Decompress(), Verify(c))组装；
+未设置Keys时panic；未设置Nonces时使用CacheNonceStore，此时cache.Pool未初始化则panic
+*/
+func Verify(c VerifyConfig) func(http.Handler) http.Handler {
+	if c.Keys == nil {
+		panic("signature: VerifyConfig.Keys is required")
+	}
+	if c.MaxSkew <= 0 {
+		c.MaxSkew = 5 * time.Minute
+	}
+	if c.Nonces == nil {
+		if cache.Pool == nil {
+			panic("signature: cache.Pool is not initialized, set VerifyConfig.Nonces or init redis first")
+		}
+		c.Nonces = &CacheNonceStore{}
+	}
+	onError := c.OnError
+	if onError == nil {
+		onError = unauthorized
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := c.verify(r); err != nil {
+				onError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (c *VerifyConfig) verify(r *http.Request) error {
+	sign, ts, nonce := r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce)
+	if sign == "" || ts == "" || nonce == "" {
+		return ErrMissingSignature
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrClockSkew
+	}
+	if d := time.Since(time.Unix(sec, 0)); d > c.MaxSkew || d < -c.MaxSkew {
+		return ErrClockSkew
+	}
+	v, err := c.Keys(r.Header.Get(HeaderKeyID))
+	if err != nil || v == nil {
+		return ErrUnknownKey
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxBodySize))
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	hash := BodyHash(body)
+	if h := r.Header.Get(HeaderContentHash); h != "" && h != hash {
+		return ErrBodyHash
+	}
+	if err = v.Verify(Canonical(r.Method, r.URL.EscapedPath(), r.URL.Query(), hash, ts, nonce), sign); err != nil {
+		return err
+	}
+	// 随机数在时钟偏差的两倍时间内有效，超出后时间戳校验即可拒绝
+	ok, err := c.Nonces.Use(nonce, 2*c.MaxSkew)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReplay
+	}
+	return nil
+}
+
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("signature: request body cannot be re-read")
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// 错误详情只记录日志，不返回给调用方，避免泄露内部错误
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ErrMissingSignature, ErrUnknownKey, ErrClockSkew, ErrReplay, ErrBodyHash, ErrInvalidSignature:
+		logger.Info("HTTP验签失败：%v %v %v", r.Method, r.URL.Path, err)
+	default:
+		logger.Error("HTTP验签失败：%v %v %v", r.Method, r.URL.Path, err)
+	}
+	b, _ := json.Marshal(map[string]interface{}{"code": http.StatusUnauthorized, "message": "invalid signature"})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(b)
+}
@@ -0,0 +1,127 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	b64 "xianhetian.com/framework/algorithm/base64"
+	"xianhetian.com/framework/algorithm/rsa"
+)
+
+const (
+	HMACSHA256 = "HMAC-SHA256"
+	RSASHA256  = "RSA-SHA256"
+)
+
+const (
+	HeaderSignature   = "X-Signature"           // 签名值
+	HeaderAlgorithm   = "X-Signature-Algorithm" // 签名算法
+	HeaderKeyID       = "X-Signature-Key-Id"    // 密钥标识
+	HeaderTimestamp   = "X-Signature-Timestamp" // 签名时间，Unix秒
+	HeaderNonce       = "X-Signature-Nonce"     // 随机数，防止重放
+	HeaderContentHash = "X-Content-SHA256"      // 请求体SHA256的16进制值
+)
+
+var ErrInvalidSignature = errors.New("signature: invalid signature")
+
+// 签名
+type Signer interface {
+	Algorithm() string
+	Sign(data string) (string, error)
+}
+
+// 验签，签名正确时返回nil
+type Verifier interface {
+	Verify(data, sign string) error
+}
+
+// HMAC-SHA256签名与验签，签名值为BASE64编码
+type HMAC struct {
+	Secret []byte
+}
+
+func (h *HMAC) Algorithm() string {
+	return HMACSHA256
+}
+
+func (h *HMAC) Sign(data string) (string, error) {
+	return b64.Encode(h.mac(data)), nil
+}
+
+func (h *HMAC) Verify(data, sign string) error {
+	s, err := b64.Decode(sign)
+	if err != nil || !hmac.Equal(s, h.mac(data)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (h *HMAC) mac(data string) []byte {
+	m := hmac.New(sha256.New, h.Secret)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// 使用algorithm/rsa.Sign签名，私钥为BASE64编码的PKCS8格式
+type RSASigner struct {
+	PrivateKey string
+}
+
+func (r *RSASigner) Algorithm() string {
+	return RSASHA256
+}
+
+func (r *RSASigner) Sign(data string) (string, error) {
+	return rsa.Sign(r.PrivateKey, data)
+}
+
+// 使用algorithm/rsa.Verify验签，公钥为BASE64编码的PKIX格式
+type RSAVerifier struct {
+	PublicKey string
+}
+
+func (r *RSAVerifier) Verify(data, sign string) error {
+	if rsa.Verify(r.PublicKey, data, sign) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+/*
+生成待签名字符串，各部分以换行符连接：
+方法（大写）、路径、按键排序后的查询参数、请求体SHA256的16进制值、时间戳、随机数
+*/
+func Canonical(method, path string, query url.Values, bodyHash, timestamp, nonce string) string {
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{strings.ToUpper(method), path, canonicalQuery(query), bodyHash, timestamp, nonce}, "\n")
+}
+
+// 返回数据SHA256的16进制值
+func BodyHash(body []byte) string {
+	h := sha256.Sum256(body)
+	return hex.EncodeToString(h[:])
+}
+
+// 按键排序，同名参数按值排序，键与值均按URL编码
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -44,6 +44,23 @@ var (
 	Logger     = &logger{format: defFmt, timeFormat: defTimeFmt, minion: log.New(io.MultiWriter(writes...), "", 0)}
 )
 
+// 当前等级，随配置项log_level变化
+var curLevel = int32(getLvl(defLevel))
+
+func init() {
+	cf.Config.OnChange("log_level", func(_, lvl string) {
+		if lvl == "" {
+			lvl = "DEBUG"
+		}
+		SetLevel(lvl)
+	})
+}
+
+// 设置日志等级：ERROR、INFO、DEBUG
+func SetLevel(lvl string) {
+	atomic.StoreInt32(&curLevel, int32(getLvl(lvl)))
+}
+
 // 格式化信息结构体
 type inf struct {
 	id       uint64
@@ -108,7 +125,7 @@ func (l *logger) debug(p ...interface{}) {
 }
 
 func (l *logger) logInternal(lvl level, msg string, pos int) {
-	if i := getLvl(defLevel); lvl > i {
+	if i := level(atomic.LoadInt32(&curLevel)); lvl > i {
 		return
 	}
 	// Calldepth指调用的深度，为0时，打印当前调用文件及行数。为1时，打印上级调用的文件及行数，依次类推。
@@ -0,0 +1,165 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrMissingToken = errors.New("token: missing token")
+	ErrInvalidToken = errors.New("token: invalid or expired token")
+	ErrIPMismatch   = errors.New("token: client ip mismatch")
+)
+
+type bodyKey struct{}
+
+// Token认证中间件配置
+type AuthConfig struct {
+	// Token来源，按顺序尝试，格式为"header:名称"、"cookie:名称"、"query:名称"；
+	// 默认为"header:Authorization"，请求头中的"Bearer "前缀会被去掉
+	Sources []string
+	// 不需要认证的路径，以*结尾时按前缀匹配
+	SkipPaths []string
+	// 是否跳过客户端IP与Body.IP一致性的校验，默认校验，Body.IP为空时不校验
+	SkipIPCheck bool
+	// 服务前的可信代理层数，大于0时从X-Forwarded-For取客户端IP，见ClientIP；
+	// 0表示不信任请求头，直接使用连接的对端地址
+	ProxyHops int
+	// 认证失败时的响应，默认返回401及JSON错误信息
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+/*
+返回Token认证中间件
+认证成功后Body保存在请求的context中，通过FromContext获取
+*/
+func Auth(c AuthConfig) func(http.Handler) http.Handler {
+	sources := c.Sources
+	if len(sources) == 0 {
+		sources = []string{"header:Authorization"}
+	}
+	onError := c.OnError
+	if onError == nil {
+		onError = unauthorized
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip(c.SkipPaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			str := extract(r, sources)
+			if str == "" {
+				onError(w, r, ErrMissingToken)
+				return
+			}
+			ok, body := Verify(str)
+			if !ok {
+				onError(w, r, ErrInvalidToken)
+				return
+			}
+			if !c.SkipIPCheck && body.IP != "" && body.IP != ClientIP(r, c.ProxyHops) {
+				onError(w, r, ErrIPMismatch)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), body)))
+		})
+	}
+}
+
+// 返回携带Body的context
+func NewContext(ctx context.Context, body *Body) context.Context {
+	return context.WithValue(ctx, bodyKey{}, body)
+}
+
+// 获取认证中间件保存在context中的Body
+func FromContext(ctx context.Context) (*Body, bool) {
+	body, ok := ctx.Value(bodyKey{}).(*Body)
+	return body, ok
+}
+
+/*
+获取客户端IP，hops为服务前的可信代理层数
+hops大于0时取X-Forwarded-For从右往左第hops个地址，每层代理在右侧追加其对端地址，
+左侧的地址可由客户端伪造，不可使用；地址数不足时使用最左侧的地址，
+没有X-Forwarded-For时使用X-Real-IP；hops为0或请求头均不存在时使用连接的对端地址
+*/
+func ClientIP(r *http.Request, hops int) string {
+	if hops > 0 {
+		var addrs []string
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			for _, a := range strings.Split(v, ",") {
+				if a = strings.TrimSpace(a); a != "" {
+					addrs = append(addrs, a)
+				}
+			}
+		}
+		if len(addrs) > 0 {
+			if hops > len(addrs) {
+				hops = len(addrs)
+			}
+			return addrs[len(addrs)-hops]
+		}
+		if ip := r.Header.Get("X-Real-IP"); ip != "" {
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func extract(r *http.Request, sources []string) string {
+	for _, src := range sources {
+		i := strings.Index(src, ":")
+		if i < 0 {
+			continue
+		}
+		kind, name := src[:i], src[i+1:]
+		var v string
+		switch kind {
+		case "header":
+			v = r.Header.Get(name)
+			if len(v) > 7 && strings.EqualFold(v[:7], "Bearer ") {
+				v = v[7:]
+			}
+		case "cookie":
+			if c, err := r.Cookie(name); err == nil {
+				v = c.Value
+			}
+		case "query":
+			v = r.URL.Query().Get(name)
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func skip(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, p[:len(p)-1]) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	b, _ := json.Marshal(map[string]interface{}{"code": http.StatusUnauthorized, "message": err.Error()})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(b)
+}
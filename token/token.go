@@ -16,11 +16,13 @@ const (
 )
 
 var (
-	pubKey  = cf.Config.DefaultString("sec_pub_key", "MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQCOOPl/m02msH5ORJ2IgWQcW6T466vc9KhRiWvnvS8Ksk2iIWOubVbRJujA3LTp1XGPdm///oowpPTXh4eFW6dg7ymcQWZCM1FJe3i9sC2eOqueUGcXgizAp9oUnzXuYpWgG+yYFvQiUTapMYQZxT+YA4efSzenxf5LzohoE+i+HwIDAQAB")
-	priKey  = cf.Config.DefaultString("sec_pri_key", "MIICdQIBADANBgkqhkiG9w0BAQEFAASCAl8wggJbAgEAAoGBAI44+X+bTaawfk5EnYiBZBxbpPjrq9z0qFGJa+e9LwqyTaIhY65tVtEm6MDctOnVcY92b//+ijCk9NeHh4Vbp2DvKZxBZkIzUUl7eL2wLZ46q55QZxeCLMCn2hSfNe5ilaAb7JgW9CJRNqkxhBnFP5gDh59LN6fF/kvOiGgT6L4fAgMBAAECgYAO2Z4bl+C8xfL6Qynbxf7pAxyvrRPt51Hn6ZxtvxA5YrK+ehQJc3s8LX7iHGl7fQD1hN1e8noFaEP0eT9KSm6ohV4EKRVLLgaiAdEgLcoAkAiWpztphUxr1vUTrqfCw8OZ/OBtrtBIqnHPFl44e83vcCB0phZSWeDNglfKnB1lgQJBANQpmcD2A7Ex//Ao8MA/3qUHFWfFcFn34/1NFOfC66N6Th5f/0/wSzCmIdGdsVcNkdsdmin167htnV59VtTyrd8CQQCrm+EqTXFMvpR16G99LxdiuKxhbW/pnvP1cx/TJ2Nu2PadubFia1/o/gJpPbrFHyj8FjEHjo8wuas3BLWX/3fBAkAbZ1gtvVkSvSOS0KbwHg/S/ww7wBvX8xXmtNsbaGjpT7XhZILkv2Pm376EhbrPRLhvNe6gttwAkV//QW9CyCm/AkBtwS16O7t55O3Yl0cu3j5rskb1rOOFnFbVJcM17hwnGfZonAn6M0hNIJ/0JTndtvckeyDyf1fPRwBdGNL3mrlBAkBX3Q5ELpYOI91PpCppsv19LyvbBQSc7bsSivKemg/lSaocrDYUmSCya/wHI9o7Y766ZpwLrr1ctQnEonJZHmD2")
+	pubKey  = cf.Config.String("sec_pub_key") // 验证Token签名的RSA公钥，BASE64编码
+	priKey  = cf.Config.String("sec_pri_key") // 签发Token的RSA私钥，BASE64编码，可使用ENC(...)加密保存
 	timeout = int64(cf.Config.DefaultInt("token_timeout", "3600"))
 )
 
+var ErrNoKey = errors.New("token: sec_pri_key is not configured")
+
 type Token struct {
 	Header Header    // 头信息
 	Body   Body      // 传输数据
@@ -48,9 +50,12 @@ type Signature struct {
 /*
 创建一个新Token
 传入参数：data(包含数据的Body结构体）
-返回结果：string(Token字符串)；error(错误)
+返回结果：string(Token字符串)；error(错误)，未配置sec_pri_key时返回ErrNoKey
 */
 func NewToken(data Body) (string, error) {
+	if priKey == "" {
+		return "", ErrNoKey
+	}
 	if data.Timeout == 0 {
 		data.Timeout = time.Now().Unix() + timeout
 	}
@@ -67,10 +72,13 @@ func NewToken(data Body) (string, error) {
 /*
 验证Token
 传入参数：需要验证的Token字符串
-返回结果：success(是否验证成功)；body（数据）
+返回结果：success(是否验证成功)；body（数据），未配置sec_pub_key时总是失败
 */
 func Verify(str string) (success bool, body *Body) {
 	success = false
+	if pubKey == "" {
+		return
+	}
 	b, _ := b64.URLDecode(str)
 	token := Token{}
 	if json.Unmarshal(b, &token) != nil {
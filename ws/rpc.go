@@ -0,0 +1,295 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const rpcVersion = "2.0"
+
+var (
+	ErrRpcTimeout      = errors.New("ws: rpc call timeout")
+	ErrRpcDisconnected = errors.New("ws: rpc connection disconnected")
+	ErrRpcClosed       = errors.New("ws: rpc client closed")
+)
+
+// 服务端主动推送通知的处理方法，在独立的goroutine中按接收顺序依次执行，可在其中调用Call
+type NotifyHandler func(params json.RawMessage)
+
+// JSON-RPC 2.0 错误对象
+type RpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("ws: rpc error %d: %s", e.Code, e.Message)
+}
+
+// 基于WsClient的JSON-RPC 2.0客户端，接管WsClient的Input与Status通道
+type RpcClient struct {
+	Status   <-chan Status // 转发WsClient的连接状态，缓冲区满时丢弃最早的状态，不读取也不影响调用
+	Timeout  time.Duration // 默认调用超时时间
+	ws       *WsClient
+	seq      uint64
+	mu       sync.Mutex
+	pending  map[uint64]chan *rpcMessage
+	handlers map[string]NotifyHandler
+	closed   bool
+	notices  []func() // 待执行的通知处理
+	running  bool     // 是否有goroutine正在执行通知处理
+}
+
+type rpcMessage struct {
+	Version string           `json:"jsonrpc"`
+	Id      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *RpcError        `json:"error,omitempty"`
+}
+
+type rpcRequest struct {
+	Version string      `json:"jsonrpc"`
+	Id      uint64      `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// 返回一个RpcClient的实例，timeout为默认调用超时时间
+func NewRpcClient(c *WsClient, timeout time.Duration) *RpcClient {
+	stsCh := make(chan Status, 2)
+	r := &RpcClient{
+		Status:   stsCh,
+		Timeout:  timeout,
+		ws:       c,
+		pending:  make(map[uint64]chan *rpcMessage),
+		handlers: make(map[string]NotifyHandler),
+	}
+	go r.dispatch(stsCh)
+	return r
+}
+
+// 注册服务端通知的处理方法，handler为nil时移除
+func (r *RpcClient) Handle(method string, handler NotifyHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if handler == nil {
+		delete(r.handlers, method)
+		return
+	}
+	r.handlers[method] = handler
+}
+
+// 使用默认超时时间调用远程方法，result为nil时忽略返回结果
+func (r *RpcClient) Call(method string, params, result interface{}) error {
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	return r.CallContext(ctx, method, params, result)
+}
+
+// 调用远程方法，ctx取消或超时后返回
+func (r *RpcClient) CallContext(ctx context.Context, method string, params, result interface{}) error {
+	id := atomic.AddUint64(&r.seq, 1)
+	b, err := json.Marshal(&rpcRequest{Version: rpcVersion, Id: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	ch := make(chan *rpcMessage, 1)
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRpcClosed
+	}
+	r.pending[id] = ch
+	r.mu.Unlock()
+	defer r.remove(id)
+	if err = r.send(ctx, b); err != nil {
+		return err
+	}
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return ErrRpcClosed
+		}
+		if msg == nil {
+			return ErrRpcDisconnected
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-ctx.Done():
+		return ctxErr(ctx)
+	}
+}
+
+// 发送通知，服务端不返回结果
+func (r *RpcClient) Notify(method string, params interface{}) error {
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return ErrRpcClosed
+	}
+	b, err := json.Marshal(&rpcRequest{Version: rpcVersion, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	return r.send(ctx, b)
+}
+
+func (r *RpcClient) send(ctx context.Context, b []byte) error {
+	select {
+	case r.ws.Output <- b:
+		return nil
+	case <-ctx.Done():
+		return ctxErr(ctx)
+	}
+}
+
+func (r *RpcClient) remove(id uint64) {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+}
+
+func (r *RpcClient) dispatch(stsCh chan Status) {
+	defer close(stsCh)
+	input, status := r.ws.Input, r.ws.Status
+	for input != nil || status != nil {
+		select {
+		case msg, ok := <-input:
+			if !ok {
+				input = nil
+				r.failAll(true)
+				continue
+			}
+			r.receive(msg)
+		case sts, ok := <-status:
+			if !ok {
+				status = nil
+				continue
+			}
+			if sts.State == DISCONNECTED {
+				r.failAll(false)
+			}
+			forward(stsCh, sts)
+		}
+	}
+}
+
+// 非阻塞地转发状态，缓冲区满时丢弃最早的一个，避免无人读取Status时阻塞调用结果的接收
+func forward(stsCh chan Status, sts Status) {
+	for {
+		select {
+		case stsCh <- sts:
+			return
+		default:
+		}
+		select {
+		case <-stsCh:
+		default:
+		}
+	}
+}
+
+func (r *RpcClient) receive(b []byte) {
+	msg := new(rpcMessage)
+	if err := json.Unmarshal(b, msg); err != nil {
+		return
+	}
+	if msg.Id == nil || msg.Method != "" {
+		r.mu.Lock()
+		handler := r.handlers[msg.Method]
+		r.mu.Unlock()
+		if handler != nil {
+			r.notify(func() { handler(msg.Params) })
+		}
+		return
+	}
+	id, err := strconv.ParseUint(string(*msg.Id), 10, 64)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	ch, ok := r.pending[id]
+	delete(r.pending, id)
+	r.mu.Unlock()
+	if ok {
+		ch <- msg
+	}
+}
+
+// 通知处理放入队列，由单独的goroutine依次执行，避免阻塞dispatch接收调用结果
+func (r *RpcClient) notify(fn func()) {
+	r.mu.Lock()
+	r.notices = append(r.notices, fn)
+	if r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	r.mu.Unlock()
+	go r.drain()
+}
+
+func (r *RpcClient) drain() {
+	for {
+		r.mu.Lock()
+		if len(r.notices) == 0 {
+			r.running = false
+			r.mu.Unlock()
+			return
+		}
+		fn := r.notices[0]
+		r.notices[0] = nil
+		r.notices = r.notices[1:]
+		r.mu.Unlock()
+		fn()
+	}
+}
+
+// 使所有等待中的调用失败，closed为true时客户端不再接受新调用
+func (r *RpcClient) failAll(closed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, ch := range r.pending {
+		if closed {
+			close(ch)
+		} else {
+			ch <- nil
+		}
+		delete(r.pending, id)
+	}
+	if closed {
+		r.closed = true
+	}
+}
+
+func ctxErr(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrRpcTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"xianhetian.com/framework/logger"
+)
+
+// WsClient的运行统计快照
+type Stats struct {
+	State         State         // 当前连接状态
+	Connects      uint64        // 成功建立连接的次数
+	Reconnects    uint64        // 重连成功的次数，不含首次连接
+	DialFailures  uint64        // 连接失败的次数
+	ConnectedAt   time.Time     // 本次连接建立的时间，未连接时为零值
+	ConnectedTime time.Duration // 累计连接时长，包含本次连接
+	MessagesIn    uint64        // 接收的消息数
+	MessagesOut   uint64        // 发送的消息数
+	BytesIn       uint64        // 接收的字节数
+	BytesOut      uint64        // 发送的字节数
+	Dropped       uint64        // 发送失败或关闭时丢弃的消息数
+	PingRTT       time.Duration // 最近一次PING的往返时间
+}
+
+type stats struct {
+	trace        bool
+	url          string
+	connects     uint64
+	dialFailures uint64
+	messagesIn   uint64
+	messagesOut  uint64
+	bytesIn      uint64
+	bytesOut     uint64
+	dropped      uint64
+	pingAt       int64 // 最近一次发送PING的时间，单位：纳秒
+	pingRTT      int64
+	mu           sync.Mutex
+	connectedAt  time.Time
+	connected    time.Duration
+}
+
+// 返回WsClient当前的运行统计
+func (c *WsClient) Stats() Stats {
+	s := c.stats
+	st := Stats{
+		Connects:     atomic.LoadUint64(&s.connects),
+		DialFailures: atomic.LoadUint64(&s.dialFailures),
+		MessagesIn:   atomic.LoadUint64(&s.messagesIn),
+		MessagesOut:  atomic.LoadUint64(&s.messagesOut),
+		BytesIn:      atomic.LoadUint64(&s.bytesIn),
+		BytesOut:     atomic.LoadUint64(&s.bytesOut),
+		Dropped:      atomic.LoadUint64(&s.dropped),
+		PingRTT:      time.Duration(atomic.LoadInt64(&s.pingRTT)),
+	}
+	if st.Connects > 0 {
+		st.Reconnects = st.Connects - 1
+	}
+	s.mu.Lock()
+	st.ConnectedTime = s.connected
+	if !s.connectedAt.IsZero() {
+		st.State = CONNECTED
+		st.ConnectedAt = s.connectedAt
+		st.ConnectedTime += time.Since(s.connectedAt)
+	}
+	s.mu.Unlock()
+	return st
+}
+
+func (s *stats) connect() {
+	n := atomic.AddUint64(&s.connects, 1)
+	s.mu.Lock()
+	s.connectedAt = time.Now()
+	s.mu.Unlock()
+	s.event("connected", "connects", n)
+}
+
+func (s *stats) disconnect(err error) {
+	s.mu.Lock()
+	if s.connectedAt.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	d := time.Since(s.connectedAt)
+	s.connected += d
+	s.connectedAt = time.Time{}
+	s.mu.Unlock()
+	s.event("disconnected", "duration", d, "error", err)
+}
+
+func (s *stats) dialFailed(err error) {
+	n := atomic.AddUint64(&s.dialFailures, 1)
+	s.event("dial_failed", "failures", n, "error", err)
+}
+
+func (s *stats) received(n int) {
+	atomic.AddUint64(&s.messagesIn, 1)
+	atomic.AddUint64(&s.bytesIn, uint64(n))
+}
+
+func (s *stats) sent(n int) {
+	atomic.AddUint64(&s.messagesOut, 1)
+	atomic.AddUint64(&s.bytesOut, uint64(n))
+}
+
+func (s *stats) drop(n uint64) {
+	if n == 0 {
+		return
+	}
+	total := atomic.AddUint64(&s.dropped, n)
+	s.event("dropped", "count", n, "total", total)
+}
+
+func (s *stats) ping() {
+	atomic.StoreInt64(&s.pingAt, time.Now().UnixNano())
+}
+
+func (s *stats) pong() {
+	at := atomic.SwapInt64(&s.pingAt, 0)
+	if at == 0 {
+		return
+	}
+	rtt := time.Duration(time.Now().UnixNano() - at)
+	atomic.StoreInt64(&s.pingRTT, int64(rtt))
+	s.event("pong", "rtt", rtt)
+}
+
+// Options.Trace开启时通过logger输出事件，kv为成对的字段名与值
+func (s *stats) event(name string, kv ...interface{}) {
+	if !s.trace {
+		return
+	}
+	fields := map[string]string{"event": name, "url": s.url}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == nil {
+			continue
+		}
+		fields[kv[i].(string)] = fmt.Sprint(kv[i+1])
+	}
+	if name == "dial_failed" || name == "dropped" {
+		logger.Error("ws事件：%v", fields)
+		return
+	}
+	logger.Debug("ws事件：%v", fields)
+}
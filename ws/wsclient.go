@@ -28,14 +28,57 @@ type WsClient struct {
 	Output  chan<- []byte
 	Status  <-chan Status
 	Command chan<- Command
+	stats   *stats
 }
 
 type Status struct {
-	State State
-	Error error
+	State     State
+	Error     error
+	CloseCode int    // 对端关闭连接时发送的关闭码，未收到关闭帧时为0
+	CloseText string // 对端关闭连接时发送的关闭原因
 }
 
+// WsClient的可选配置
+type Options struct {
+	Compression          bool // 是否协商permessage-deflate压缩
+	CompressionLevel     int  // 压缩级别，取值参考compress/flate，0使用默认级别
+	CompressionThreshold int  // 消息长度小于该值时不压缩发送
+
+	CloseCode    int           // QUIT时发送的关闭码，默认1000
+	CloseReason  string        // QUIT时发送的关闭原因
+	CloseTimeout time.Duration // QUIT时等待对端回应关闭帧的时间，默认3秒
+
+	OnConnect   []ConnectHook // 每次连接建立后、Output恢复发送前依次执行，如认证、重新订阅
+	HookTimeout time.Duration // OnConnect钩子读写的总超时时间，默认10秒
+
+	Trace bool // 是否通过logger输出连接、断开、丢弃消息等事件
+}
+
+// 连接建立后执行的钩子，可直接在conn上读写，读写超过HookTimeout时返回超时错误；返回错误时关闭连接并按重连间隔重试
+type ConnectHook func(conn *websocket.Conn) error
+
+// 返回一个使用默认配置的WsClient实例
 func New(url string, headers http.Header) *WsClient {
+	return NewWithOptions(url, headers, nil)
+}
+
+// 返回一个WsClient的实例，opts为nil时使用默认配置
+func NewWithOptions(url string, headers http.Header, opts *Options) *WsClient {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.CloseCode == 0 {
+		o.CloseCode = websocket.CloseNormalClosure
+	}
+	if o.CloseTimeout <= 0 {
+		o.CloseTimeout = 3 * time.Second
+	}
+	if o.HookTimeout <= 0 {
+		o.HookTimeout = 10 * time.Second
+	}
+	opts = &o
+	st := &stats{trace: opts.Trace, url: url}
 	inpCh := make(chan []byte, 8)
 	outCh := make(chan []byte, 8)
 	stsCh := make(chan Status, 2)
@@ -52,9 +95,11 @@ func New(url string, headers http.Header) *WsClient {
 		var writing bool
 		var conn *websocket.Conn
 		msgType := websocket.BinaryMessage
-		go keepAlive(&wg, ioEventCh, controlCh)
-		go connect(&wg, url, headers, stsCh, conReturnCh, ioEventCh, conCancelCh)
-		defer safeClose(&wg, conn, conReturnCh, inpCh, outCh, stsCh, cmdCh, controlCh, ioEventCh, conCancelCh, rErrorCh, wErrorCh)
+		go keepAlive(ioEventCh, controlCh)
+		go connect(&wg, url, headers, opts, st, stsCh, conReturnCh, ioEventCh, conCancelCh)
+		defer func() {
+			safeClose(&wg, conn, st, conReturnCh, inpCh, outCh, stsCh, cmdCh, controlCh, ioEventCh, conCancelCh, rErrorCh, wErrorCh)
+		}()
 	LOOP:
 		for {
 			select {
@@ -64,35 +109,46 @@ func New(url string, headers http.Header) *WsClient {
 				}
 				reading = true
 				writing = true
-				go read(&wg, conn, inpCh, ioEventCh, rErrorCh)
-				go write(&wg, conn, msgType, outCh, ioEventCh, controlCh, wErrorCh)
+				st.connect()
+				go read(&wg, conn, st, inpCh, ioEventCh, rErrorCh)
+				go write(&wg, conn, opts, st, msgType, outCh, ioEventCh, controlCh, wErrorCh)
 			case err := <-rErrorCh:
 				reading = false
+				st.disconnect(err)
 				if writing {
 					controlCh <- QUIT
-					stsCh <- Status{State: DISCONNECTED, Error: err}
+					stsCh <- newStatus(DISCONNECTED, err)
 					continue
 				}
 				if conn != nil {
 					conn.Close()
 					conn = nil
 				}
-				go connect(&wg, url, headers, stsCh, conReturnCh, ioEventCh, conCancelCh)
+				go connect(&wg, url, headers, opts, st, stsCh, conReturnCh, ioEventCh, conCancelCh)
 			case err := <-wErrorCh:
 				writing = false
+				st.disconnect(err)
 				if reading {
 					if conn != nil {
 						conn.Close()
 						conn = nil
 					}
-					stsCh <- Status{State: DISCONNECTED, Error: err}
+					stsCh <- newStatus(DISCONNECTED, err)
 					continue
 				}
-				go connect(&wg, url, headers, stsCh, conReturnCh, ioEventCh, conCancelCh)
+				if conn != nil {
+					conn.Close()
+					conn = nil
+				}
+				go connect(&wg, url, headers, opts, st, stsCh, conReturnCh, ioEventCh, conCancelCh)
 			case cmd, ok := <-cmdCh:
 				switch {
 				case !ok || cmd == QUIT:
+					if conn != nil && reading {
+						closeHandshake(conn, opts, rErrorCh)
+					}
 					if reading || writing || conn != nil {
+						st.disconnect(nil)
 						stsCh <- Status{State: DISCONNECTED}
 					}
 					break LOOP
@@ -114,22 +170,21 @@ func New(url string, headers http.Header) *WsClient {
 			}
 		}
 	}()
-	return &WsClient{URL: url, Headers: headers, Input: inpCh, Output: outCh, Status: stsCh, Command: cmdCh}
+	return &WsClient{URL: url, Headers: headers, Input: inpCh, Output: outCh, Status: stsCh, Command: cmdCh, stats: st}
 }
 
-func connect(wg *sync.WaitGroup, url string, headers http.Header,
+func connect(wg *sync.WaitGroup, url string, headers http.Header, opts *Options, st *stats,
 	stsCh chan Status, conReturnCh chan *websocket.Conn, ioEventCh, conCancelCh chan bool) {
 	wg.Add(1)
 	defer wg.Done()
 	for {
-		dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
-		conn, _, err := dialer.Dial(url, headers)
+		conn, err := dial(url, headers, opts, st, ioEventCh)
 		if err == nil {
-			conn.SetPongHandler(func(string) error { ioEventCh <- true; return nil })
 			conReturnCh <- conn
 			stsCh <- Status{State: CONNECTED}
 			return
 		}
+		st.dialFailed(err)
 		stsCh <- Status{State: DISCONNECTED, Error: err}
 		select {
 		case <-time.After(30 * time.Second):
@@ -140,10 +195,39 @@ func connect(wg *sync.WaitGroup, url string, headers http.Header,
 	}
 }
 
-func keepAlive(wg *sync.WaitGroup,
-	ioEventCh chan bool, controlCh chan Command) {
-	wg.Add(1)
-	defer wg.Done()
+// 建立连接并依次执行OnConnect钩子，任一步骤失败时关闭连接
+func dial(url string, headers http.Header, opts *Options, st *stats, ioEventCh chan bool) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second, EnableCompression: opts.Compression}
+	conn, _, err := dialer.Dial(url, headers)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Compression && opts.CompressionLevel != 0 {
+		if err = conn.SetCompressionLevel(opts.CompressionLevel); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	conn.SetPongHandler(func(string) error { st.pong(); ioEventCh <- true; return nil })
+	if len(opts.OnConnect) == 0 {
+		return conn, nil
+	}
+	// 钩子执行期间设置读写超时，避免对端无响应时阻塞重连，完成后清除
+	deadline := time.Now().Add(opts.HookTimeout)
+	conn.SetReadDeadline(deadline)
+	conn.SetWriteDeadline(deadline)
+	for _, hook := range opts.OnConnect {
+		if err = hook(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ws: on connect hook failed: %v", err)
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	conn.SetWriteDeadline(time.Time{})
+	return conn, nil
+}
+
+func keepAlive(ioEventCh chan bool, controlCh chan Command) {
 	dur := 30 * time.Second
 	timer := time.NewTimer(dur)
 	timer.Stop()
@@ -166,7 +250,7 @@ LOOP:
 	}
 }
 
-func write(wg *sync.WaitGroup, conn *websocket.Conn, msgType int,
+func write(wg *sync.WaitGroup, conn *websocket.Conn, opts *Options, st *stats, msgType int,
 	outCh chan []byte, ioEventCh chan bool, controlCh chan Command, wErrorCh chan error) {
 	wg.Add(1)
 	defer wg.Done()
@@ -180,13 +264,19 @@ LOOP:
 			}
 			ioEventCh <- true
 			if err := conn.SetWriteDeadline(time.Now().Add(3 * time.Second)); err != nil {
+				st.drop(1)
 				wErrorCh <- err
 				break LOOP
 			}
+			if opts.Compression {
+				conn.EnableWriteCompression(len(msg) >= opts.CompressionThreshold)
+			}
 			if err := conn.WriteMessage(msgType, msg); err != nil {
+				st.drop(1)
 				wErrorCh <- err
 				break LOOP
 			}
+			st.sent(len(msg))
 			conn.SetWriteDeadline(time.Time{})
 		case cmd, ok := <-controlCh:
 			if !ok {
@@ -198,6 +288,7 @@ LOOP:
 				wErrorCh <- errors.New("cancelled")
 				break LOOP
 			case PING:
+				st.ping()
 				if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(3*time.Second)); err != nil {
 					wErrorCh <- errors.New("cancelled")
 					break LOOP
@@ -211,12 +302,13 @@ LOOP:
 	}
 }
 
-func read(wg *sync.WaitGroup, conn *websocket.Conn,
+func read(wg *sync.WaitGroup, conn *websocket.Conn, st *stats,
 	inpCh chan []byte, ioEventCh chan bool, rErrorCh chan error) {
 	wg.Add(1)
 	defer wg.Done()
 	for {
 		if _, msg, err := conn.ReadMessage(); err == nil {
+			st.received(len(msg))
 			ioEventCh <- true
 			inpCh <- msg
 		} else {
@@ -226,13 +318,24 @@ func read(wg *sync.WaitGroup, conn *websocket.Conn,
 	}
 }
 
-func safeClose(wg *sync.WaitGroup, conn *websocket.Conn,
+// 发送关闭帧并等待对端回应，超时后直接返回
+func closeHandshake(conn *websocket.Conn, opts *Options, rErrorCh chan error) {
+	msg := websocket.FormatCloseMessage(opts.CloseCode, opts.CloseReason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(opts.CloseTimeout)); err != nil {
+		return
+	}
+	select {
+	case <-rErrorCh:
+	case <-time.After(opts.CloseTimeout):
+	}
+}
+
+func safeClose(wg *sync.WaitGroup, conn *websocket.Conn, st *stats,
 	conReturnCh chan *websocket.Conn, inpCh, outCh chan []byte, stsCh chan Status, cmdCh, controlCh chan Command,
 	ioEventCh, conCancelCh chan bool, rErrorCh, wErrorCh chan error) {
 	if conn != nil {
 		conn.Close()
 	}
-	close(ioEventCh)
 	close(controlCh)
 	close(conCancelCh)
 	<-time.After(50 * time.Millisecond)
@@ -242,7 +345,9 @@ LOOP:
 		case _, ok := <-outCh:
 			if !ok {
 				outCh = nil
+				continue
 			}
+			st.drop(1)
 		case _, ok := <-cmdCh:
 			if !ok {
 				inpCh = nil
@@ -267,10 +372,22 @@ LOOP:
 		}
 	}
 	wg.Wait()
+	// 读写协程退出后才能关闭ioEventCh，否则会向已关闭的通道发送数据
+	close(ioEventCh)
 	close(inpCh)
 	close(stsCh)
 }
 
+// 生成连接状态，对端发送关闭帧时记录关闭码与原因
+func newStatus(state State, err error) Status {
+	sts := Status{State: state, Error: err}
+	if e, ok := err.(*websocket.CloseError); ok {
+		sts.CloseCode = e.Code
+		sts.CloseText = e.Text
+	}
+	return sts
+}
+
 type State byte
 
 type Command byte
@@ -282,7 +399,7 @@ func (s State) String() string {
 	case CONNECTED:
 		return "CONNECTED"
 	}
-	return fmt.Sprintf("UNKNOWN STATUS %s", s)
+	return fmt.Sprintf("UNKNOWN STATUS %d", byte(s))
 }
 
 func (c Command) String() string {
@@ -296,5 +413,5 @@ func (c Command) String() string {
 	case USEBINARY:
 		return "USE_BINARY"
 	}
-	return fmt.Sprintf("UNKNOWN COMMAND %s", c)
+	return fmt.Sprintf("UNKNOWN COMMAND %d", byte(c))
 }